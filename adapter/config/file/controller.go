@@ -0,0 +1,342 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package file provides a read-only config store backed by a directory of
+// YAML files.
+//
+// Each file holds a stream of documents separated by "---" lines. A document
+// has the same form as the input of istioctl: the configuration type and the
+// spec of the configuration object, for example
+//
+//	type: route-rule
+//	name: reviews-default
+//	spec:
+//	  destination: reviews.default.svc.cluster.local
+//	  precedence: 1
+//	  route:
+//	  - tags:
+//	      version: v1
+//
+// The name is informational, objects are identified by the key derived from
+// their spec. An optional namespace field places the object in a namespace;
+// objects without a namespace belong to the empty default namespace.
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/howeyc/fsnotify"
+
+	"istio.io/pilot/adapter/config/memory"
+	"istio.io/pilot/model"
+)
+
+// eventDelay is the quiet period after a change to the directory before the
+// files are reloaded, so that partially written files are not loaded
+const eventDelay = 100 * time.Millisecond
+
+// errReadOnly is returned by the mutating store methods
+var errReadOnly = errors.New("file config store is read-only")
+
+// documentSeparator splits a YAML stream into documents
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// document is a single configuration object in a file
+type document struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Spec      interface{} `json:"spec"`
+}
+
+type controller struct {
+	root       string
+	descriptor model.ConfigDescriptor
+
+	// cache holds the merged configuration of all files and notifies the
+	// handlers of the differences applied on every reload
+	cache model.ConfigStoreCache
+
+	// files holds the last valid configuration objects of every file
+	mu    sync.Mutex
+	files map[string][]model.Config
+}
+
+// NewController creates a config store cache that loads the configuration
+// objects from the files in the root directory and reloads them on changes.
+// Files that fail to parse or validate are reported, and their last valid
+// content is retained.
+func NewController(root string, descriptor model.ConfigDescriptor) (model.ConfigStoreCache, error) {
+	out := &controller{
+		root:       root,
+		descriptor: descriptor,
+		cache:      memory.NewController(memory.Make(descriptor)),
+		files:      make(map[string][]model.Config),
+	}
+	if err := out.reload(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controller) ConfigDescriptor() model.ConfigDescriptor {
+	return c.descriptor
+}
+
+// Get implements config registry method
+func (c *controller) Get(typ, key, namespace string) (proto.Message, bool, string) {
+	return c.cache.Get(typ, key, namespace)
+}
+
+// List implements config registry method
+func (c *controller) List(typ, namespace string) ([]model.Config, error) {
+	return c.cache.List(typ, namespace)
+}
+
+// Post implements config registry method
+func (c *controller) Post(proto.Message, string) (string, error) {
+	return "", errReadOnly
+}
+
+// Put implements config registry method
+func (c *controller) Put(proto.Message, string, string) (string, error) {
+	return "", errReadOnly
+}
+
+// Delete implements config registry method
+func (c *controller) Delete(string, string, string) error {
+	return errReadOnly
+}
+
+// RegisterEventHandler implements config cache method
+func (c *controller) RegisterEventHandler(typ string, handler func(model.Config, model.Event)) {
+	c.cache.RegisterEventHandler(typ, handler)
+}
+
+// Run implements config cache method
+func (c *controller) Run(stop <-chan struct{}) {
+	// the watch is established before the handlers are notified, and the
+	// directory is reloaded to pick up changes made since the creation
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		glog.Warningf("failed to create a watcher for config files: %v", err)
+	} else if err = fw.Watch(c.root); err != nil {
+		glog.Warningf("watching %s encounters an error %v", c.root, err)
+		_ = fw.Close()
+	} else {
+		if err = c.reload(); err != nil {
+			glog.Warning(err)
+		}
+		go c.watch(fw, stop)
+	}
+	c.cache.Run(stop)
+}
+
+// watch reloads the directory on changes until a signal is received
+func (c *controller) watch(fw *fsnotify.Watcher, stop <-chan struct{}) {
+	defer func() {
+		if err := fw.Close(); err != nil {
+			glog.Warningf("closing watcher encounters an error %v", err)
+		}
+	}()
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case ev := <-fw.Event:
+			glog.V(2).Infof("Change to %q is detected", ev.Name)
+			reload = time.After(eventDelay)
+
+		case <-reload:
+			reload = nil
+			glog.V(2).Infof("Reloading config files in %q", c.root)
+			if err := c.reload(); err != nil {
+				glog.Warning(err)
+			}
+
+		case err := <-fw.Error:
+			glog.Warningf("config file watcher error: %v", err)
+
+		case <-stop:
+			glog.V(2).Info("Config file watcher is terminated")
+			return
+		}
+	}
+}
+
+// reload parses the files in the directory and applies the differences with
+// the current configuration to the cache
+func (c *controller) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(c.root)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || strings.HasPrefix(name, ".") || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(c.root, name)
+		present[path] = true
+		configs, err := c.parseFile(path)
+		if err != nil {
+			glog.Warningf("Failed to load config file %s, retaining its last valid content: %v", path, err)
+			continue
+		}
+		c.files[path] = configs
+	}
+	for path := range c.files {
+		if !present[path] {
+			delete(c.files, path)
+		}
+	}
+
+	return c.sync()
+}
+
+// parseFile reads and validates all configuration objects in a file
+func (c *controller) parseFile(path string) ([]model.Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+	out := make([]model.Config, 0)
+	for i, chunk := range documentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		config, err := c.parseDocument(chunk)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("document %d: %v", i, err))
+			continue
+		}
+		out = append(out, *config)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return out, nil
+}
+
+func (c *controller) parseDocument(chunk string) (*model.Config, error) {
+	var doc document
+	if err := yaml.Unmarshal([]byte(chunk), &doc); err != nil {
+		return nil, err
+	}
+	schema, ok := c.descriptor.GetByType(doc.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", doc.Type)
+	}
+	spec, err := yaml.Marshal(doc.Spec)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := schema.FromYAML(string(spec))
+	if err != nil {
+		return nil, err
+	}
+	if err = schema.Validate(msg); err != nil {
+		return nil, err
+	}
+	return &model.Config{
+		Type:      schema.Type,
+		Key:       schema.Key(msg),
+		Namespace: doc.Namespace,
+		Content:   msg,
+	}, nil
+}
+
+// sync applies the merged content of the files to the cache, posting new
+// objects, updating modified objects, and deleting removed objects. Objects
+// defined in multiple files are taken from the first file in lexical order.
+// sync must be called with the lock held.
+func (c *controller) sync() error {
+	paths := make([]string, 0, len(c.files))
+	for path := range c.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// desired objects are indexed by the namespaced key
+	desired := make(map[string]map[string]model.Config)
+	for _, typ := range c.descriptor.Types() {
+		desired[typ] = make(map[string]model.Config)
+	}
+	for _, path := range paths {
+		for _, config := range c.files[path] {
+			key := model.NamespacedKey(config.Namespace, config.Key)
+			if _, exists := desired[config.Type][key]; exists {
+				glog.Warningf("Duplicate %s %q in %s is ignored", config.Type, key, path)
+				continue
+			}
+			desired[config.Type][key] = config
+		}
+	}
+
+	var errs error
+	for _, typ := range c.descriptor.Types() {
+		existing, err := c.cache.List(typ, model.NamespaceAll)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for _, config := range existing {
+			key := model.NamespacedKey(config.Namespace, config.Key)
+			want, ok := desired[typ][key]
+			switch {
+			case !ok:
+				if err := c.cache.Delete(typ, config.Key, config.Namespace); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			case !proto.Equal(want.Content, config.Content):
+				if _, err := c.cache.Put(want.Content, config.Namespace, config.Revision); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+			delete(desired[typ], key)
+		}
+		keys := make([]string, 0, len(desired[typ]))
+		for key := range desired[typ] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			config := desired[typ][key]
+			if _, err := c.cache.Post(config.Content, config.Namespace); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	return errs
+}
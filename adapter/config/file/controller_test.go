@@ -0,0 +1,207 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	proxyconfig "istio.io/api/proxy/v1/config"
+	"istio.io/pilot/model"
+)
+
+const (
+	rules = `
+type: route-rule
+name: reviews-default
+spec:
+  name: reviews-default
+  destination: reviews.default.svc.cluster.local
+  precedence: 1
+  route:
+  - tags:
+      version: v1
+---
+type: destination-policy
+spec:
+  destination: reviews.default.svc.cluster.local
+  policy:
+  - load_balancing:
+      name: RANDOM
+`
+	updatedRules = `
+type: route-rule
+spec:
+  name: reviews-default
+  destination: reviews.default.svc.cluster.local
+  precedence: 2
+  route:
+  - tags:
+      version: v2
+`
+	namespacedRules = `
+type: route-rule
+namespace: default
+spec:
+  name: reviews-default
+  destination: reviews.default.svc.cluster.local
+---
+type: route-rule
+namespace: istio-system
+spec:
+  name: reviews-default
+  destination: reviews.default.svc.cluster.local
+`
+	invalidRules = `
+type: route-rule
+spec:
+  name: reviews-default
+  destination: reviews.default.svc.cluster.local
+  route:
+  - tags:
+      version: v1
+    weight: 150
+`
+)
+
+type event struct {
+	config model.Config
+	event  model.Event
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectEvents(t *testing.T, events <-chan event, want ...model.Event) []model.Config {
+	out := make([]model.Config, 0, len(want))
+	for _, ev := range want {
+		select {
+		case got := <-events:
+			if got.event != ev {
+				t.Errorf("got %s event for %s %s, want %s", got.event, got.config.Type, got.config.Key, ev)
+			}
+			out = append(out, got.config)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", ev)
+		}
+	}
+	return out
+}
+
+func TestController(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "reviews.yaml")
+	writeFile(t, path, rules)
+	writeFile(t, filepath.Join(dir, "README.md"), "ignored")
+
+	ctl, err := NewController(dir, model.IstioConfigTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := model.MakeIstioStore(ctl)
+	if got := store.RouteRules(); len(got) != 1 {
+		t.Errorf("got route rules %v, want one", got)
+	}
+	if got := store.DestinationPolicy("reviews.default.svc.cluster.local", nil); got.GetLoadBalancing().GetName() !=
+		proxyconfig.LoadBalancing_RANDOM {
+		t.Errorf("got destination policy %v", got)
+	}
+	if _, err = ctl.Post(&proxyconfig.RouteRule{}, ""); err == nil {
+		t.Error("expected error for Post")
+	}
+
+	events := make(chan event, 10)
+	for _, typ := range model.IstioConfigTypes.Types() {
+		ctl.RegisterEventHandler(typ, func(config model.Config, ev model.Event) {
+			events <- event{config: config, event: ev}
+		})
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	// initial content
+	expectEvents(t, events, model.EventAdd, model.EventAdd)
+
+	// the destination policy is removed and the rule is updated
+	writeFile(t, path, updatedRules)
+	configs := expectEvents(t, events, model.EventUpdate, model.EventDelete)
+	if rule, ok := configs[0].Content.(*proxyconfig.RouteRule); !ok || rule.Precedence != 2 {
+		t.Errorf("got updated config %v", configs[0].Content)
+	}
+
+	// the last valid content is retained, and a new file is loaded
+	writeFile(t, path, invalidRules)
+	writeFile(t, filepath.Join(dir, "policy.yml"), `
+type: destination-policy
+spec:
+  destination: ratings.default.svc.cluster.local
+`)
+	configs = expectEvents(t, events, model.EventAdd)
+	if configs[0].Type != model.DestinationPolicy {
+		t.Errorf("got added %s, want %s", configs[0].Type, model.DestinationPolicy)
+	}
+	if got := store.RouteRules(); len(got) != 1 {
+		t.Errorf("got route rules %v, want the last valid rule", got)
+	}
+
+	// removing the file deletes its content
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, events, model.EventDelete)
+	if got := store.RouteRules(); len(got) != 0 {
+		t.Errorf("got route rules %v, want none", got)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	writeFile(t, filepath.Join(dir, "rules.yaml"), namespacedRules)
+
+	ctl, err := NewController(dir, model.IstioConfigTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ctl.List(model.RouteRule, model.NamespaceAll); len(got) != 2 {
+		t.Errorf("got route rules %v, want two", got)
+	}
+	got, _ := ctl.List(model.RouteRule, "istio-system")
+	if len(got) != 1 || got[0].Namespace != "istio-system" {
+		t.Errorf("got route rules %v, want one in istio-system", got)
+	}
+	if _, exists, _ := ctl.Get(model.RouteRule, "reviews-default", "default"); !exists {
+		t.Error("missing route rule in namespace default")
+	}
+	rules := model.MakeIstioStore(ctl).RouteRules()
+	if _, exists := rules["istio-system/reviews-default"]; !exists || len(rules) != 2 {
+		t.Errorf("got route rules %v, want rules keyed by namespace", rules)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history provides a config store decorator that records the
+// mutations of the config objects and rolls the objects back to their
+// recorded revisions.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"istio.io/pilot/model"
+)
+
+// Make decorates a config store with a history of at most limit mutations per
+// config object. A non-positive limit retains all mutations. The empty
+// namespace stands for the default namespace of the store, which keys the
+// history of the objects created without a namespace.
+//
+// The history is kept in memory: it is lost on restart, and it records only
+// the mutations through this decorator, so that replicas sharing a store each
+// keep a partial history.
+func Make(store model.ConfigStore, namespace string, limit int) model.ConfigHistory {
+	return &history{
+		ConfigStore: store,
+		namespace:   namespace,
+		limit:       limit,
+		now:         time.Now,
+		log:         make(map[objectKey][]model.ConfigRevision),
+	}
+}
+
+// objectKey identifies a config object in the store
+type objectKey struct {
+	typ       string
+	key       string
+	namespace string
+}
+
+type history struct {
+	model.ConfigStore
+	namespace string
+	limit     int
+	now       func() time.Time
+
+	// mu serializes the mutations to capture the previous content
+	mu  sync.Mutex
+	log map[objectKey][]model.ConfigRevision
+}
+
+// authorStore attributes the mutations to an author
+type authorStore struct {
+	*history
+	author string
+}
+
+// Get implements config store method
+func (h *history) Get(typ, key, namespace string) (proto.Message, bool, string) {
+	return h.ConfigStore.Get(typ, key, h.normalize(namespace))
+}
+
+// Post implements config store method
+func (h *history) Post(config proto.Message, namespace string) (string, error) {
+	return h.post(config, namespace, "")
+}
+
+// Put implements config store method
+func (h *history) Put(config proto.Message, namespace, oldRevision string) (string, error) {
+	return h.put(config, namespace, oldRevision, "")
+}
+
+// Delete implements config store method
+func (h *history) Delete(typ, key, namespace string) error {
+	return h.delete(typ, key, namespace, "")
+}
+
+// WithAuthor implements config history method
+func (h *history) WithAuthor(author string) model.ConfigStore {
+	return &authorStore{history: h, author: author}
+}
+
+// Post implements config store method
+func (a *authorStore) Post(config proto.Message, namespace string) (string, error) {
+	return a.post(config, namespace, a.author)
+}
+
+// Put implements config store method
+func (a *authorStore) Put(config proto.Message, namespace, oldRevision string) (string, error) {
+	return a.put(config, namespace, oldRevision, a.author)
+}
+
+// Delete implements config store method
+func (a *authorStore) Delete(typ, key, namespace string) error {
+	return a.delete(typ, key, namespace, a.author)
+}
+
+// History implements config history method
+func (h *history) History(typ, key, namespace string) ([]model.ConfigRevision, error) {
+	if _, ok := h.ConfigDescriptor().GetByType(typ); !ok {
+		return nil, errors.New("unknown type")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.log[objectKey{typ: typ, key: key, namespace: h.normalize(namespace)}]
+	if len(entries) == 0 {
+		return nil, &model.ItemNotFoundError{Key: key}
+	}
+	out := make([]model.ConfigRevision, len(entries))
+	copy(out, entries)
+	return out, nil
+}
+
+// Rollback implements config history method. The rollback fails if the object
+// has been changed since the last recorded mutation, either by another writer
+// or because the store does not reflect the last mutation yet.
+func (h *history) Rollback(typ, key, namespace, revision, author string) (string, error) {
+	if _, ok := h.ConfigDescriptor().GetByType(typ); !ok {
+		return "", errors.New("unknown type")
+	}
+	namespace = h.normalize(namespace)
+	k := objectKey{typ: typ, key: key, namespace: namespace}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var target proto.Message
+	entries := h.log[k]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if revision != "" && entries[i].Revision == revision {
+			target = entries[i].Content
+			break
+		}
+	}
+	if target == nil {
+		return "", &model.ItemNotFoundError{
+			Msg: fmt.Sprintf("revision %q of %s %q not found in the history", revision, typ, key),
+		}
+	}
+
+	previous, exists, current := h.ConfigStore.Get(typ, key, namespace)
+	last := entries[len(entries)-1]
+	if deleted := last.Event == model.EventDelete; exists == deleted || exists && current != last.Revision {
+		return "", &model.RevisionConflictError{
+			Key: key,
+			Msg: fmt.Sprintf("%s %q has changed since its last recorded revision", typ, key),
+		}
+	}
+	entry := model.ConfigRevision{Author: author, Content: target, Previous: previous}
+	var err error
+	if exists {
+		entry.Event = model.EventUpdate
+		entry.Revision, err = h.ConfigStore.Put(target, namespace, current)
+	} else {
+		entry.Event = model.EventAdd
+		entry.Revision, err = h.ConfigStore.Post(target, namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+	h.record(k, entry)
+	return entry.Revision, nil
+}
+
+func (h *history) post(config proto.Message, namespace, author string) (string, error) {
+	namespace = h.normalize(namespace)
+	k, ok := h.objectKey(config, namespace)
+	if !ok {
+		return h.ConfigStore.Post(config, namespace)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rev, err := h.ConfigStore.Post(config, namespace)
+	if err != nil {
+		return "", err
+	}
+	h.record(k, model.ConfigRevision{
+		Revision: rev,
+		Event:    model.EventAdd,
+		Author:   author,
+		Content:  config,
+	})
+	return rev, nil
+}
+
+func (h *history) put(config proto.Message, namespace, oldRevision, author string) (string, error) {
+	namespace = h.normalize(namespace)
+	k, ok := h.objectKey(config, namespace)
+	if !ok {
+		return h.ConfigStore.Put(config, namespace, oldRevision)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous, _, _ := h.ConfigStore.Get(k.typ, k.key, namespace)
+	rev, err := h.ConfigStore.Put(config, namespace, oldRevision)
+	if err != nil {
+		return "", err
+	}
+	h.record(k, model.ConfigRevision{
+		Revision: rev,
+		Event:    model.EventUpdate,
+		Author:   author,
+		Content:  config,
+		Previous: previous,
+	})
+	return rev, nil
+}
+
+func (h *history) delete(typ, key, namespace, author string) error {
+	namespace = h.normalize(namespace)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous, _, _ := h.ConfigStore.Get(typ, key, namespace)
+	if err := h.ConfigStore.Delete(typ, key, namespace); err != nil {
+		return err
+	}
+	h.record(objectKey{typ: typ, key: key, namespace: namespace}, model.ConfigRevision{
+		Event:    model.EventDelete,
+		Author:   author,
+		Previous: previous,
+	})
+	return nil
+}
+
+// normalize replaces the empty namespace with the default namespace
+func (h *history) normalize(namespace string) string {
+	if namespace == "" {
+		return h.namespace
+	}
+	return namespace
+}
+
+// objectKey derives the key of a config object from its content
+func (h *history) objectKey(config proto.Message, namespace string) (objectKey, bool) {
+	schema, ok := h.ConfigDescriptor().GetByMessageName(proto.MessageName(config))
+	if !ok {
+		return objectKey{}, false
+	}
+	return objectKey{typ: schema.Type, key: schema.Key(config), namespace: namespace}, true
+}
+
+// record appends a mutation to the history of an object and must be called
+// with the lock held
+func (h *history) record(k objectKey, entry model.ConfigRevision) {
+	entry.Timestamp = h.now()
+	entries := append(h.log[k], entry)
+	if h.limit > 0 && len(entries) > h.limit {
+		entries = entries[len(entries)-h.limit:]
+	}
+	h.log[k] = entries
+}
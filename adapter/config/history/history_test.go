@@ -0,0 +1,191 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/pilot/adapter/config/memory"
+	"istio.io/pilot/model"
+	"istio.io/pilot/test/mock"
+)
+
+func TestHistoryInvariant(t *testing.T) {
+	store := Make(memory.Make(mock.Types), "default", 0)
+	mock.CheckMapInvariant(store, t, "default", 10)
+	mock.CheckMapInvariant(store.WithAuthor("alice"), t, "istio-system", 10)
+}
+
+func TestHistory(t *testing.T) {
+	store := Make(memory.Make(mock.Types), "default", 0)
+	first, second := mock.Make(0), mock.Make(0)
+	second.Pairs[0].Value = "changed"
+
+	if _, err := store.History(mock.Type, first.Key, ""); err == nil {
+		t.Error("expected error for an object without history")
+	}
+	if _, err := store.History("unknown", first.Key, ""); err == nil {
+		t.Error("expected error for an unknown type")
+	}
+
+	rev, err := store.Post(first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.WithAuthor("alice").Put(second, "", rev); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.WithAuthor("bob").Delete(mock.Type, first.Key, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.History(mock.Type, first.Key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	want := []model.ConfigRevision{
+		{Revision: rev, Event: model.EventAdd, Content: first},
+		{Revision: entries[1].Revision, Event: model.EventUpdate, Author: "alice", Content: second, Previous: first},
+		{Event: model.EventDelete, Author: "bob", Previous: second},
+	}
+	for i := range entries {
+		if entries[i].Timestamp.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+		entries[i].Timestamp = want[i].Timestamp
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got history %v, want %v", entries, want)
+	}
+
+	// the empty namespace is the default namespace, and objects in other
+	// namespaces have a separate history
+	if got, _ := store.History(mock.Type, first.Key, "default"); len(got) != len(entries) {
+		t.Errorf("got history %v in the default namespace, want %d entries", got, len(entries))
+	}
+	if _, err := store.History(mock.Type, first.Key, "istio-system"); err == nil {
+		t.Error("expected error for an object in another namespace")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	store := Make(memory.Make(mock.Types), "default", 2)
+	config := mock.Make(0)
+	rev, err := store.Post(config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if rev, err = store.Put(config, "", rev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := store.History(mock.Type, config.Key, "")
+	if len(entries) != 2 || entries[1].Revision != rev {
+		t.Errorf("got history %v, want the last 2 mutations", entries)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	backing := memory.Make(mock.Types)
+	store := Make(backing, "default", 0)
+	first, second := mock.Make(0), mock.Make(0)
+	second.Pairs[0].Value = "changed"
+
+	rev1, err := store.Post(first, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev2, err := store.Put(second, "default", rev1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.Rollback(mock.Type, first.Key, "default", "unknown", "alice"); err == nil {
+		t.Error("expected error for an unknown revision")
+	}
+	if _, err = store.Rollback(mock.Type, first.Key, "istio-system", rev1, "alice"); err == nil {
+		t.Error("expected error for a revision in another namespace")
+	}
+
+	// rollback an update
+	rev3, err := store.Rollback(mock.Type, first.Key, "default", rev1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _, rev := backing.Get(mock.Type, first.Key, "default"); !reflect.DeepEqual(content, first) || rev != rev3 {
+		t.Errorf("got %v at revision %q, want %v at revision %q", content, rev, first, rev3)
+	}
+
+	// rollback a deletion
+	if err = store.Delete(mock.Type, first.Key, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Rollback(mock.Type, first.Key, "default", rev2, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if content, exists, _ := backing.Get(mock.Type, first.Key, "default"); !exists || !reflect.DeepEqual(content, second) {
+		t.Errorf("got %v, want %v", content, second)
+	}
+
+	entries, _ := store.History(mock.Type, first.Key, "default")
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+	if last := entries[4]; last.Event != model.EventAdd || last.Author != "bob" || last.Previous != nil {
+		t.Errorf("got rollback entry %+v, want a creation by bob", last)
+	}
+	if rollback := entries[2]; rollback.Event != model.EventUpdate || rollback.Author != "alice" ||
+		!reflect.DeepEqual(rollback.Previous, second) {
+		t.Errorf("got rollback entry %+v, want an update by alice", rollback)
+	}
+}
+
+func TestRollbackConflict(t *testing.T) {
+	backing := memory.Make(mock.Types)
+	store := Make(backing, "default", 0)
+	first, second := mock.Make(0), mock.Make(0)
+	second.Pairs[0].Value = "changed"
+
+	rev, err := store.Post(first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an update that bypasses the history
+	if _, err = backing.Put(second, "default", rev); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Rollback(mock.Type, first.Key, "", rev, "alice")
+	if _, ok := err.(*model.RevisionConflictError); !ok {
+		t.Errorf("got error %v, want a revision conflict", err)
+	}
+	if content, _, _ := backing.Get(mock.Type, first.Key, "default"); !reflect.DeepEqual(content, second) {
+		t.Errorf("got %v, want the content of the update %v", content, second)
+	}
+
+	// a deletion that bypasses the history
+	if err = backing.Delete(mock.Type, first.Key, "default"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Rollback(mock.Type, first.Key, "", rev, "alice")
+	if _, ok := err.(*model.RevisionConflictError); !ok {
+		t.Errorf("got error %v, want a revision conflict", err)
+	}
+}
@@ -17,31 +17,49 @@ package memory
 
 import (
 	"errors"
-	"time"
+	"strconv"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 
 	"istio.io/pilot/model"
 )
 
-// Make creates an in-memory config store from a config descriptor
+// Make creates an in-memory config store from a config descriptor. The store
+// is safe for concurrent use and assigns monotonically increasing revisions.
+// The default namespace of the store is the empty namespace.
 func Make(descriptor model.ConfigDescriptor) model.ConfigStore {
 	out := store{
 		descriptor: descriptor,
-		data:       make(map[string]map[string]proto.Message),
-		revs:       make(map[string]map[string]string),
+		data:       make(map[string]map[storeKey]proto.Message),
+		revs:       make(map[string]map[storeKey]string),
 	}
 	for _, typ := range descriptor.Types() {
-		out.data[typ] = make(map[string]proto.Message)
-		out.revs[typ] = make(map[string]string)
+		out.data[typ] = make(map[storeKey]proto.Message)
+		out.revs[typ] = make(map[storeKey]string)
 	}
 	return &out
 }
 
+// storeKey identifies a config object of a type
+type storeKey struct {
+	namespace string
+	key       string
+}
+
 type store struct {
 	descriptor model.ConfigDescriptor
-	data       map[string]map[string]proto.Message
-	revs       map[string]map[string]string
+
+	mu       sync.RWMutex
+	data     map[string]map[storeKey]proto.Message
+	revs     map[string]map[storeKey]string
+	revision uint64
+}
+
+// nextRevision must be called with the lock held
+func (cr *store) nextRevision() string {
+	cr.revision++
+	return strconv.FormatUint(cr.revision, 10)
 }
 
 func (cr *store) ConfigDescriptor() model.ConfigDescriptor {
@@ -49,49 +67,61 @@ func (cr *store) ConfigDescriptor() model.ConfigDescriptor {
 }
 
 // Get implements config registry method
-func (cr *store) Get(typ, key string) (proto.Message, bool, string) {
+func (cr *store) Get(typ, key, namespace string) (proto.Message, bool, string) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
 	_, ok := cr.data[typ]
 	if !ok {
 		return nil, false, ""
 	}
-	val, exists := cr.data[typ][key]
-	return val, exists, cr.revs[typ][key]
+	k := storeKey{namespace: namespace, key: key}
+	val, exists := cr.data[typ][k]
+	return val, exists, cr.revs[typ][k]
 }
 
 // List implements config registry method
-func (cr *store) List(typ string) ([]model.Config, error) {
+func (cr *store) List(typ, namespace string) ([]model.Config, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
 	_, ok := cr.data[typ]
 	if !ok {
 		return nil, nil
 	}
 	out := make([]model.Config, 0, len(cr.data[typ]))
-	for key, elt := range cr.data[typ] {
+	for k, elt := range cr.data[typ] {
+		if namespace != model.NamespaceAll && namespace != k.namespace {
+			continue
+		}
 		out = append(out, model.Config{
-			Type:     typ,
-			Key:      key,
-			Revision: cr.revs[typ][key],
-			Content:  elt,
+			Type:      typ,
+			Key:       k.key,
+			Namespace: k.namespace,
+			Revision:  cr.revs[typ][k],
+			Content:   elt,
 		})
 	}
 	return out, nil
 }
 
 // Delete implements config registry method
-func (cr *store) Delete(typ, key string) error {
+func (cr *store) Delete(typ, key, namespace string) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
 	_, ok := cr.data[typ]
 	if !ok {
 		return errors.New("unknown type")
 	}
-	if _, exists := cr.data[typ][key]; exists {
-		delete(cr.data[typ], key)
-		delete(cr.revs[typ], key)
+	k := storeKey{namespace: namespace, key: key}
+	if _, exists := cr.data[typ][k]; exists {
+		delete(cr.data[typ], k)
+		delete(cr.revs[typ], k)
 		return nil
 	}
 	return &model.ItemNotFoundError{Key: key}
 }
 
 // Post implements config registry method
-func (cr *store) Post(config proto.Message) (string, error) {
+func (cr *store) Post(config proto.Message, namespace string) (string, error) {
 	schema, ok := cr.descriptor.GetByMessageName(proto.MessageName(config))
 	if !ok {
 		return "", errors.New("unknown type")
@@ -100,19 +130,21 @@ func (cr *store) Post(config proto.Message) (string, error) {
 		return "", err
 	}
 	typ := schema.Type
-	key := schema.Key(config)
-	_, exists := cr.data[typ][key]
+	k := storeKey{namespace: namespace, key: schema.Key(config)}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	_, exists := cr.data[typ][k]
 	if !exists {
-		rev := time.Now().String()
-		cr.revs[typ][key] = rev
-		cr.data[typ][key] = config
+		rev := cr.nextRevision()
+		cr.revs[typ][k] = rev
+		cr.data[typ][k] = config
 		return rev, nil
 	}
-	return "", &model.ItemAlreadyExistsError{Key: key}
+	return "", &model.ItemAlreadyExistsError{Key: k.key}
 }
 
 // Put implements config registry method
-func (cr *store) Put(config proto.Message, oldRevision string) (string, error) {
+func (cr *store) Put(config proto.Message, namespace, oldRevision string) (string, error) {
 	schema, ok := cr.descriptor.GetByMessageName(proto.MessageName(config))
 	if !ok {
 		return "", errors.New("unknown type")
@@ -121,17 +153,19 @@ func (cr *store) Put(config proto.Message, oldRevision string) (string, error) {
 		return "", err
 	}
 	typ := schema.Type
-	key := schema.Key(config)
-	_, exists := cr.data[typ][key]
+	k := storeKey{namespace: namespace, key: schema.Key(config)}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	_, exists := cr.data[typ][k]
 	if !exists {
-		return "", &model.ItemNotFoundError{Key: key}
+		return "", &model.ItemNotFoundError{Key: k.key}
 	}
-	if oldRevision != cr.revs[typ][key] {
+	if oldRevision != cr.revs[typ][k] {
 		return "", errors.New("old revision")
 	}
 
-	rev := time.Now().String()
-	cr.revs[typ][key] = rev
-	cr.data[typ][key] = config
+	rev := cr.nextRevision()
+	cr.revs[typ][k] = rev
+	cr.data[typ][k] = config
 	return rev, nil
 }
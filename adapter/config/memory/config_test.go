@@ -15,12 +15,79 @@
 package memory
 
 import (
+	"sync"
 	"testing"
 
+	"istio.io/pilot/model"
 	"istio.io/pilot/test/mock"
 )
 
 func TestStoreInvariant(t *testing.T) {
 	store := Make(mock.Types)
-	mock.CheckMapInvariant(store, t, 10)
+	mock.CheckMapInvariant(store, t, "", 10)
+	mock.CheckMapInvariant(store, t, "default", 10)
+}
+
+func TestStoreRevisions(t *testing.T) {
+	store := Make(mock.Types)
+	n := 50
+
+	var wg sync.WaitGroup
+	revs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rev, err := store.Post(mock.Make(i), "")
+			if err != nil {
+				t.Error(err)
+			}
+			revs <- rev
+		}(i)
+	}
+	wg.Wait()
+	close(revs)
+
+	seen := make(map[string]bool)
+	for rev := range revs {
+		if seen[rev] {
+			t.Errorf("duplicate revision %q", rev)
+		}
+		seen[rev] = true
+	}
+
+	config := mock.Make(0)
+	_, _, rev := store.Get(mock.Type, config.Key, "")
+	next, err := store.Put(config, "", rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == rev || seen[next] {
+		t.Errorf("got reused revision %q after update", next)
+	}
+}
+
+func TestStoreNamespaces(t *testing.T) {
+	store := Make(mock.Types)
+	config := mock.Make(0)
+	for _, namespace := range []string{"", "default", "istio-system"} {
+		if _, err := store.Post(config, namespace); err != nil {
+			t.Fatalf("Post(%q) => %v", namespace, err)
+		}
+	}
+
+	if l, _ := store.List(mock.Type, model.NamespaceAll); len(l) != 3 {
+		t.Errorf("got %d objects in all namespaces, want 3", len(l))
+	}
+	l, _ := store.List(mock.Type, "default")
+	if len(l) != 1 || l[0].Namespace != "default" || l[0].Key != config.Key {
+		t.Errorf("got %v in namespace default, want a single object", l)
+	}
+
+	if err := store.Delete(mock.Type, config.Key, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := store.Get(mock.Type, config.Key, "istio-system"); !exists {
+		t.Error("deleting an object removed an object with the same key in another namespace")
+	}
 }
@@ -0,0 +1,160 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+
+	"istio.io/pilot/model"
+)
+
+type controller struct {
+	store model.ConfigStore
+
+	// mu guards the queue and the handlers, and serializes the store mutations
+	// with their notifications so that the queue follows the store order
+	mu       sync.Mutex
+	queue    []configEvent
+	notify   chan struct{}
+	handlers map[string][]func(model.Config, model.Event)
+}
+
+type configEvent struct {
+	config model.Config
+	event  model.Event
+}
+
+// NewController wraps a config store to notify the registered handlers of the
+// changes made through the controller. Notifications are queued and delivered
+// in order by the Run loop.
+func NewController(store model.ConfigStore) model.ConfigStoreCache {
+	return &controller{
+		store:    store,
+		notify:   make(chan struct{}, 1),
+		handlers: make(map[string][]func(model.Config, model.Event)),
+	}
+}
+
+func (c *controller) ConfigDescriptor() model.ConfigDescriptor {
+	return c.store.ConfigDescriptor()
+}
+
+// Get implements config registry method
+func (c *controller) Get(typ, key, namespace string) (proto.Message, bool, string) {
+	return c.store.Get(typ, key, namespace)
+}
+
+// List implements config registry method
+func (c *controller) List(typ, namespace string) ([]model.Config, error) {
+	return c.store.List(typ, namespace)
+}
+
+// Post implements config registry method
+func (c *controller) Post(config proto.Message, namespace string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rev, err := c.store.Post(config, namespace)
+	if err == nil {
+		c.schedule(config, namespace, rev, model.EventAdd)
+	}
+	return rev, err
+}
+
+// Put implements config registry method
+func (c *controller) Put(config proto.Message, namespace, oldRevision string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rev, err := c.store.Put(config, namespace, oldRevision)
+	if err == nil {
+		c.schedule(config, namespace, rev, model.EventUpdate)
+	}
+	return rev, err
+}
+
+// Delete implements config registry method
+func (c *controller) Delete(typ, key, namespace string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config, exists, rev := c.store.Get(typ, key, namespace)
+	if err := c.store.Delete(typ, key, namespace); err != nil {
+		return err
+	}
+	if exists {
+		c.schedule(config, namespace, rev, model.EventDelete)
+	}
+	return nil
+}
+
+// RegisterEventHandler implements config cache method
+func (c *controller) RegisterEventHandler(typ string, handler func(model.Config, model.Event)) {
+	c.mu.Lock()
+	c.handlers[typ] = append(c.handlers[typ], handler)
+	c.mu.Unlock()
+}
+
+// Run implements config cache method
+func (c *controller) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-c.notify:
+			for {
+				c.mu.Lock()
+				if len(c.queue) == 0 {
+					c.mu.Unlock()
+					break
+				}
+				var item configEvent
+				item, c.queue = c.queue[0], c.queue[1:]
+				handlers := c.handlers[item.config.Type]
+				c.mu.Unlock()
+
+				for _, handler := range handlers {
+					handler(item.config, item.event)
+				}
+			}
+		}
+	}
+}
+
+// schedule queues a notification for the handlers of the config type and must
+// be called with the lock held
+func (c *controller) schedule(config proto.Message, namespace, rev string, event model.Event) {
+	schema, ok := c.store.ConfigDescriptor().GetByMessageName(proto.MessageName(config))
+	if !ok {
+		glog.Warningf("Unknown config type %q", proto.MessageName(config))
+		return
+	}
+	c.queue = append(c.queue, configEvent{
+		config: model.Config{
+			Type:      schema.Type,
+			Key:       schema.Key(config),
+			Namespace: namespace,
+			Revision:  rev,
+			Content:   config,
+		},
+		event: event,
+	})
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+		// a notification is already pending
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/pilot/model"
+	"istio.io/pilot/test/mock"
+)
+
+func TestControllerInvariant(t *testing.T) {
+	ctl := NewController(Make(mock.Types))
+	mock.CheckMapInvariant(ctl, t, "default", 10)
+}
+
+func TestControllerEvents(t *testing.T) {
+	ctl := NewController(Make(mock.Types))
+	events := make(chan model.Event, 10)
+	configs := make(chan model.Config, 10)
+	ctl.RegisterEventHandler(mock.Type, func(config model.Config, ev model.Event) {
+		events <- ev
+		configs <- config
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	config := mock.Make(0)
+	rev, err := ctl.Post(config, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev, err = ctl.Put(config, "default", rev); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctl.Delete(mock.Type, config.Key, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctl.Delete(mock.Type, config.Key, "default"); err == nil {
+		t.Error("expected error deleting a missing config")
+	}
+
+	for _, want := range []model.Event{model.EventAdd, model.EventUpdate, model.EventDelete} {
+		select {
+		case ev := <-events:
+			got := <-configs
+			if ev != want || got.Type != mock.Type || got.Key != config.Key || got.Namespace != "default" {
+				t.Errorf("got %s event for %s %s in %q, want %s", ev, got.Type, got.Key, got.Namespace, want)
+			}
+			if ev == model.EventDelete && got.Revision != rev {
+				t.Errorf("got revision %q on delete, want %q", got.Revision, rev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", want)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected %s event", ev)
+	default:
+	}
+}
+
+func TestControllerEventOrder(t *testing.T) {
+	ctl := NewController(Make(mock.Types))
+	config := mock.Make(0)
+
+	// concurrent writers race on the same key; the notifications must follow
+	// the order of the changes in the store
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = ctl.Post(config, "default")
+				_ = ctl.Delete(mock.Type, config.Key, "default")
+			}
+		}()
+	}
+	wg.Wait()
+
+	var events []model.Event
+	ctl.RegisterEventHandler(mock.Type, func(_ model.Config, ev model.Event) {
+		events = append(events, ev)
+	})
+	stop := make(chan struct{})
+	go func() {
+		// the queue is drained synchronously after a single notification
+		time.Sleep(100 * time.Millisecond)
+		close(stop)
+	}()
+	ctl.Run(stop)
+
+	if len(events) == 0 {
+		t.Fatal("no events delivered")
+	}
+	for i, ev := range events {
+		want := model.EventAdd
+		if i%2 == 1 {
+			want = model.EventDelete
+		}
+		if ev != want {
+			t.Fatalf("got %s event at position %d, want %s", ev, i, want)
+		}
+	}
+}
@@ -33,6 +33,10 @@ const (
 	namespace = "namespace"
 )
 
+// AuthorHeader is the request header that names the author of a config
+// mutation. The author is recorded if the registry keeps the config history.
+const AuthorHeader = "X-Istio-Author"
+
 // APIServiceOptions are the options available for configuration on the API
 // Version is the API version e.g. v1 for /v1/config
 type APIServiceOptions struct {
@@ -91,6 +95,21 @@ func (api *API) Register(container *restful.Container) {
 		To(api.DeleteConfig).
 		Doc("Delete a config"))
 
+	ws.Route(ws.
+		GET(fmt.Sprintf("/config/{%s}/{%s}/{%s}/history", kind, namespace, name)).
+		To(api.GetConfigHistory).
+		Doc("Get the revision history of a config. The history is kept in memory by each apiserver " +
+			"replica, and lists only the changes made through it since it started").
+		Writes([]Revision{}))
+
+	ws.Route(ws.
+		POST(fmt.Sprintf("/config/{%s}/{%s}/{%s}/rollback", kind, namespace, name)).
+		To(api.RollbackConfig).
+		Doc("Rollback a config to a revision in the history of this apiserver replica. " +
+			"The rollback fails with a conflict if the config has changed since its last recorded revision").
+		Reads(Rollback{}).
+		Writes(Revision{}))
+
 	ws.Route(ws.
 		GET(fmt.Sprintf("/config/{%s}/{%s}", kind, namespace)).
 		To(api.ListConfigs).
@@ -26,6 +26,7 @@ import (
 
 	restful "github.com/emicklei/go-restful"
 
+	"istio.io/pilot/adapter/config/history"
 	"istio.io/pilot/adapter/config/memory"
 	"istio.io/pilot/model"
 	test_util "istio.io/pilot/test/util"
@@ -61,11 +62,18 @@ func makeAPIServer(r model.ConfigStore) *API {
 }
 
 func makeAPIRequest(api *API, method, url string, data []byte, t *testing.T) (int, []byte) {
+	return makeAuthoredAPIRequest(api, method, url, data, "", t)
+}
+
+func makeAuthoredAPIRequest(api *API, method, url string, data []byte, author string, t *testing.T) (int, []byte) {
 	httpRequest, err := http.NewRequest(method, url, bytes.NewBuffer(data))
-	httpRequest.Header.Set("Content-Type", "application/json")
 	if err != nil {
 		t.Fatal(err)
 	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if author != "" {
+		httpRequest.Header.Set(AuthorHeader, author)
+	}
 	httpWriter := httptest.NewRecorder()
 	container := restful.NewContainer()
 	api.Register(container)
@@ -152,6 +160,102 @@ func TestListConfig(t *testing.T) {
 	makeAPIRequestWriteFails(api, "GET", "/test/config/route-rule", nil, t)
 }
 
+func TestConfigNamespaces(t *testing.T) {
+	mockReg := memory.Make(model.IstioConfigTypes)
+	api := makeAPIServer(mockReg)
+	rule := []byte(`{"type":"route-rule","name":"reviews",` +
+		`"spec":{"name":"reviews","destination":"reviews.default.svc.cluster.local"}}`)
+
+	// the same rule is owned by two namespaces
+	for _, ns := range []string{"team-a", "team-b"} {
+		status, body := makeAPIRequest(api, "POST", "/test/config/route-rule/"+ns+"/reviews", rule, t)
+		compareStatus(status, http.StatusCreated, t)
+		config := Config{}
+		if err := json.Unmarshal(body, &config); err != nil || config.Namespace != ns {
+			t.Errorf("got config %s, want namespace %s", string(body), ns)
+		}
+	}
+	status, _ := makeAPIRequest(api, "POST", "/test/config/route-rule/team-a/reviews", rule, t)
+	compareStatus(status, http.StatusConflict, t)
+
+	status, body := makeAPIRequest(api, "GET", "/test/config/route-rule/team-a", nil, t)
+	compareStatus(status, http.StatusOK, t)
+	compareListCount(body, 1, t)
+	status, body = makeAPIRequest(api, "GET", "/test/config/route-rule", nil, t)
+	compareStatus(status, http.StatusOK, t)
+	compareListCount(body, 2, t)
+
+	status, _ = makeAPIRequest(api, "DELETE", "/test/config/route-rule/team-a/reviews", nil, t)
+	compareStatus(status, http.StatusOK, t)
+	compareStoredConfig(mockReg, key{Kind: model.RouteRule, Name: "reviews", Namespace: "team-a"}, false, t)
+	compareStoredConfig(mockReg, key{Kind: model.RouteRule, Name: "reviews", Namespace: "team-b"}, true, t)
+	status, _ = makeAPIRequest(api, "GET", "/test/config/route-rule/team-b/reviews", nil, t)
+	compareStatus(status, http.StatusOK, t)
+
+	// the namespace in the body must match the URL
+	mismatched := []byte(`{"type":"route-rule","name":"reviews","namespace":"team-b",` +
+		`"spec":{"name":"reviews","destination":"reviews.default.svc.cluster.local"}}`)
+	status, _ = makeAPIRequest(api, "POST", "/test/config/route-rule/team-a/reviews", mismatched, t)
+	compareStatus(status, http.StatusBadRequest, t)
+}
+
+func TestConfigHistory(t *testing.T) {
+	backing := memory.Make(model.IstioConfigTypes)
+	mockReg := history.Make(backing, "default", 0)
+	api := makeAPIServer(mockReg)
+	url := "/test/config/route-rule/default/reviews"
+	v1 := []byte(`{"type":"route-rule","name":"reviews",` +
+		`"spec":{"name":"reviews","destination":"reviews.default.svc.cluster.local",` +
+		`"route":[{"tags":{"version":"v1"}}]}}`)
+
+	status, _ := makeAPIRequest(api, "GET", url+"/history", nil, t)
+	compareStatus(status, http.StatusNotFound, t)
+
+	status, _ = makeAuthoredAPIRequest(api, "POST", url, v1, "alice", t)
+	compareStatus(status, http.StatusCreated, t)
+	status, _ = makeAuthoredAPIRequest(api, "DELETE", url, nil, "bob", t)
+	compareStatus(status, http.StatusOK, t)
+
+	status, body := makeAPIRequest(api, "GET", url+"/history", nil, t)
+	compareStatus(status, http.StatusOK, t)
+	var revisions []Revision
+	if err := json.Unmarshal(body, &revisions); err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 ||
+		revisions[0].Operation != "add" || revisions[0].Author != "alice" || revisions[0].Spec == nil ||
+		revisions[1].Operation != "delete" || revisions[1].Author != "bob" || revisions[1].Previous == nil {
+		t.Fatalf("got history %s, want the creation by alice and the deletion by bob", string(body))
+	}
+
+	// restore the deleted rule
+	rollback := []byte(fmt.Sprintf(`{"revision":%q}`, revisions[0].Revision))
+	status, body = makeAuthoredAPIRequest(api, "POST", url+"/rollback", rollback, "carol", t)
+	compareStatus(status, http.StatusOK, t)
+	var revision Revision
+	if err := json.Unmarshal(body, &revision); err != nil || revision.Author != "carol" || revision.Operation != "add" {
+		t.Errorf("got rollback %s, want a creation by carol", string(body))
+	}
+	compareStoredConfig(mockReg, key{Kind: model.RouteRule, Name: "reviews", Namespace: "default"}, true, t)
+
+	status, _ = makeAPIRequest(api, "POST", url+"/rollback", []byte(`{"revision":"unknown"}`), t)
+	compareStatus(status, http.StatusNotFound, t)
+
+	// a change that bypasses the history conflicts with the rollback
+	if err := backing.Delete(model.RouteRule, "reviews", "default"); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = makeAPIRequest(api, "POST", url+"/rollback", rollback, t)
+	compareStatus(status, http.StatusConflict, t)
+
+	// the history requires a registry that keeps it
+	api = makeAPIServer(memory.Make(model.IstioConfigTypes))
+	status, _ = makeAPIRequest(api, "GET", url+"/history", nil, t)
+	compareStatus(status, http.StatusNotImplemented, t)
+	status, _ = makeAPIRequest(api, "POST", url+"/rollback", rollback, t)
+	compareStatus(status, http.StatusNotImplemented, t)
+}
+
 func TestConfigErrors(t *testing.T) {
 	// TODO: disable temporarily
 	t.Skip()
@@ -359,8 +463,7 @@ func compareStatus(received, expected int, t *testing.T) {
 }
 
 func compareStoredConfig(mockReg model.ConfigStore, key key, present bool, t *testing.T) {
-	// TODO: deprecated, please update
-	_, ok, _ := mockReg.Get(key.Kind, key.Name)
+	_, ok, _ := mockReg.Get(key.Kind, key.Name, key.Namespace)
 	if !ok && present {
 		t.Errorf("Expected config wasn't present in the registry for key: %+v", key)
 	} else if ok && !present {
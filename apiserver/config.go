@@ -17,6 +17,7 @@ package apiserver
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
@@ -27,14 +28,32 @@ import (
 // Config is the complete configuration including a parsed spec
 type Config struct {
 	// Type SHOULD be one of the kinds in model.IstioConfig; a route-rule, ingress-rule, or destination-policy
-	Type string      `json:"type,omitempty"`
-	Name string      `json:"name,omitempty"`
-	Spec interface{} `json:"spec,omitempty"`
+	Type      string      `json:"type,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Spec      interface{} `json:"spec,omitempty"`
 	// ParsedSpec will be one of the messages in model.IstioConfig: for example an
 	// istio.proxy.v1alpha.config.RouteRule or DestinationPolicy
 	ParsedSpec proto.Message `json:"-"`
 }
 
+// Revision is an entry in the revision history of a config
+type Revision struct {
+	// Revision of the config after the mutation, empty for a deletion
+	Revision string `json:"revision,omitempty"`
+	// Operation is one of add, update or delete
+	Operation string      `json:"operation"`
+	Author    string      `json:"author,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Spec      interface{} `json:"spec,omitempty"`
+	Previous  interface{} `json:"previous,omitempty"`
+}
+
+// Rollback is the request to restore a config to a revision in its history
+type Rollback struct {
+	Revision string `json:"revision"`
+}
+
 // ParseSpec takes the field in the config object and parses into a protobuf message
 // Then assigns it to the ParseSpec field
 func (c *Config) ParseSpec() error {
@@ -55,3 +74,39 @@ func (c *Config) ParseSpec() error {
 	glog.V(2).Infof("Parsed %v %v into %v %v", c.Type, c.Name, schema.MessageName, message)
 	return nil
 }
+
+// toSpec converts a protobuf message to the JSON form of a spec. A nil
+// message converts to a nil spec.
+func toSpec(message proto.Message) (interface{}, error) {
+	if message == nil {
+		return nil, nil
+	}
+	var schema model.ProtoSchema
+	retrieved, err := schema.ToJSON(message)
+	if err != nil {
+		return nil, err
+	}
+	var spec interface{}
+	if err = json.Unmarshal([]byte(retrieved), &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// toRevision converts a config history entry to its JSON form
+func toRevision(entry model.ConfigRevision) (Revision, error) {
+	out := Revision{
+		Revision:  entry.Revision,
+		Operation: entry.Event.String(),
+		Author:    entry.Author,
+		Timestamp: entry.Timestamp,
+	}
+	var err error
+	if out.Spec, err = toSpec(entry.Content); err != nil {
+		return Revision{}, err
+	}
+	if out.Previous, err = toSpec(entry.Previous); err != nil {
+		return Revision{}, err
+	}
+	return out, nil
+}
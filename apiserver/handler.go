@@ -43,8 +43,7 @@ func (api *API) GetConfig(request *restful.Request, response *restful.Response)
 	}
 
 	glog.V(2).Infof("Getting config from Istio registry: %+v", k)
-	// TODO: incorrect use with new registry
-	proto, ok, _ := api.registry.Get(k.Kind, k.Name)
+	proto, ok, _ := api.registry.Get(k.Kind, k.Name, k.Namespace)
 	if !ok {
 		errLocal := &model.ItemNotFoundError{Key: k.Name}
 		api.writeError(http.StatusNotFound, errLocal.Error(), response)
@@ -63,9 +62,10 @@ func (api *API) GetConfig(request *restful.Request, response *restful.Response)
 		return
 	}
 	config := Config{
-		Name: params["name"],
-		Type: params["kind"],
-		Spec: retJSON,
+		Name:      params["name"],
+		Namespace: k.Namespace,
+		Type:      params["kind"],
+		Spec:      retJSON,
 	}
 	glog.V(2).Infof("Retrieved config %+v", config)
 	if err = response.WriteHeaderAndEntity(http.StatusOK, config); err != nil {
@@ -97,9 +97,13 @@ func (api *API) AddConfig(request *restful.Request, response *restful.Response)
 		return
 	}
 
+	if err = setNamespace(config, k); err != nil {
+		api.writeError(http.StatusBadRequest, err.Error(), response)
+		return
+	}
+
 	glog.V(2).Infof("Adding config to Istio registry: key %+v, config %+v", k, config)
-	// TODO: incorrect use with new registry
-	if _, err = api.registry.Post(config.ParsedSpec); err != nil {
+	if _, err = api.store(request).Post(config.ParsedSpec, k.Namespace); err != nil {
 		response.AddHeader("Content-Type", "text/plain")
 		switch err.(type) {
 		case *model.ItemAlreadyExistsError:
@@ -138,10 +142,13 @@ func (api *API) UpdateConfig(request *restful.Request, response *restful.Respons
 		return
 	}
 
-	glog.V(2).Infof("Updating config in Istio registry: key %+v, config %+v", k, config)
+	if err = setNamespace(config, k); err != nil {
+		api.writeError(http.StatusBadRequest, err.Error(), response)
+		return
+	}
 
-	// TODO: incorrect use with new registry
-	if _, err = api.registry.Put(config.ParsedSpec, ""); err != nil {
+	glog.V(2).Infof("Updating config in Istio registry: key %+v, config %+v", k, config)
+	if _, err = api.store(request).Put(config.ParsedSpec, k.Namespace, ""); err != nil {
 		switch err.(type) {
 		case *model.ItemNotFoundError:
 			api.writeError(http.StatusNotFound, err.Error(), response)
@@ -167,8 +174,7 @@ func (api *API) DeleteConfig(request *restful.Request, response *restful.Respons
 	}
 
 	glog.V(2).Infof("Deleting config from Istio registry: %+v", k)
-	// TODO: incorrect use with new registry
-	if err = api.registry.Delete(k.Kind, k.Name); err != nil {
+	if err = api.store(request).Delete(k.Kind, k.Name, k.Namespace); err != nil {
 		switch err.(type) {
 		case *model.ItemNotFoundError:
 			api.writeError(http.StatusNotFound, err.Error(), response)
@@ -194,7 +200,7 @@ func (api *API) ListConfigs(request *restful.Request, response *restful.Response
 		return
 	}
 	glog.V(2).Infof("Getting configs of kind %s in namespace %s", kind, namespace)
-	result, err := api.registry.List(kind)
+	result, err := api.registry.List(kind, namespace)
 	if err != nil {
 		api.writeError(http.StatusInternalServerError, err.Error(), response)
 		return
@@ -215,9 +221,10 @@ func (api *API) ListConfigs(request *restful.Request, response *restful.Response
 			return
 		}
 		config := Config{
-			Name: v.Key,
-			Type: v.Type,
-			Spec: retJSON,
+			Name:      v.Key,
+			Namespace: v.Namespace,
+			Type:      v.Type,
+			Spec:      retJSON,
 		}
 		glog.V(2).Infof("Retrieved config %+v", config)
 		out = append(out, config)
@@ -227,6 +234,111 @@ func (api *API) ListConfigs(request *restful.Request, response *restful.Response
 	}
 }
 
+// GetConfigHistory retrieves the revision history of a config object from the
+// configuration registry
+func (api *API) GetConfigHistory(request *restful.Request, response *restful.Response) {
+
+	params := request.PathParameters()
+	k, err := setup(params)
+	if err != nil {
+		api.writeError(http.StatusBadRequest, err.Error(), response)
+		return
+	}
+
+	history, ok := api.registry.(model.ConfigHistory)
+	if !ok {
+		api.writeError(http.StatusNotImplemented, "the configuration registry does not keep the config history", response)
+		return
+	}
+
+	glog.V(2).Infof("Getting config history from Istio registry: %+v", k)
+	entries, err := history.History(k.Kind, k.Name, k.Namespace)
+	if err != nil {
+		switch err.(type) {
+		case *model.ItemNotFoundError:
+			api.writeError(http.StatusNotFound, err.Error(), response)
+		default:
+			api.writeError(http.StatusInternalServerError, err.Error(), response)
+		}
+		return
+	}
+
+	out := make([]Revision, 0, len(entries))
+	for _, entry := range entries {
+		revision, errLocal := toRevision(entry)
+		if errLocal != nil {
+			api.writeError(http.StatusInternalServerError, errLocal.Error(), response)
+			return
+		}
+		out = append(out, revision)
+	}
+	if err = response.WriteHeaderAndEntity(http.StatusOK, out); err != nil {
+		api.writeError(http.StatusInternalServerError, err.Error(), response)
+	}
+}
+
+// RollbackConfig restores a config object in the configuration registry to a
+// revision in its history
+func (api *API) RollbackConfig(request *restful.Request, response *restful.Response) {
+
+	params := request.PathParameters()
+	k, err := setup(params)
+	if err != nil {
+		api.writeError(http.StatusBadRequest, err.Error(), response)
+		return
+	}
+
+	history, ok := api.registry.(model.ConfigHistory)
+	if !ok {
+		api.writeError(http.StatusNotImplemented, "the configuration registry does not keep the config history", response)
+		return
+	}
+
+	rollback := &Rollback{}
+	if err = request.ReadEntity(rollback); err != nil {
+		api.writeError(http.StatusBadRequest, err.Error(), response)
+		return
+	}
+
+	glog.V(2).Infof("Rolling back config in Istio registry: key %+v, revision %q", k, rollback.Revision)
+	author := request.HeaderParameter(AuthorHeader)
+	revision, err := history.Rollback(k.Kind, k.Name, k.Namespace, rollback.Revision, author)
+	if err != nil {
+		switch err.(type) {
+		case *model.ItemNotFoundError:
+			api.writeError(http.StatusNotFound, err.Error(), response)
+		case *model.RevisionConflictError:
+			api.writeError(http.StatusConflict, err.Error(), response)
+		default:
+			api.writeError(http.StatusInternalServerError, err.Error(), response)
+		}
+		return
+	}
+
+	// respond with the history entry of the rollback
+	entries, err := history.History(k.Kind, k.Name, k.Namespace)
+	if err != nil {
+		api.writeError(http.StatusInternalServerError, err.Error(), response)
+		return
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Revision != revision {
+			continue
+		}
+		out, errLocal := toRevision(entries[i])
+		if errLocal != nil {
+			api.writeError(http.StatusInternalServerError, errLocal.Error(), response)
+			return
+		}
+		glog.V(2).Infof("Rolled back config to %+v", out)
+		if err = response.WriteHeaderAndEntity(http.StatusOK, out); err != nil {
+			api.writeError(http.StatusInternalServerError, err.Error(), response)
+		}
+		return
+	}
+	api.writeError(http.StatusInternalServerError, fmt.Sprintf("revision %q is missing from the history", revision), response)
+}
+
 // Version returns the version information of apiserver
 func (api *API) Version(request *restful.Request, response *restful.Response) {
 	glog.V(2).Infof("Returning version information")
@@ -243,6 +355,15 @@ func (api *API) writeError(status int, msg string, response *restful.Response) {
 	}
 }
 
+// store returns the registry view that attributes the mutations to the author
+// of the request if the registry keeps the config history
+func (api *API) store(request *restful.Request) model.ConfigStore {
+	if history, ok := api.registry.(model.ConfigHistory); ok {
+		return history.WithAuthor(request.HeaderParameter(AuthorHeader))
+	}
+	return api.registry
+}
+
 type key struct {
 	Kind      string
 	Name      string
@@ -261,3 +382,13 @@ func setup(params map[string]string) (key, error) {
 		Namespace: namespace,
 	}, nil
 }
+
+// setNamespace assigns the namespace in the URL to the config object, which
+// must not declare a different namespace
+func setNamespace(config *Config, k key) error {
+	if config.Namespace != "" && config.Namespace != k.Namespace {
+		return fmt.Errorf("namespace %q does not match the namespace %q in the URL", config.Namespace, k.Namespace)
+	}
+	config.Namespace = k.Namespace
+	return nil
+}
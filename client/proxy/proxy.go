@@ -27,6 +27,8 @@ type BasicHTTPRequester struct {
 	BaseURL string
 	Client  *http.Client
 	Version string
+	// Author of the config mutations, sent in the author header if set
+	Author string
 }
 
 func toCurl(request *http.Request, body string) string {
@@ -57,6 +59,9 @@ func (f *BasicHTTPRequester) Request(method, path string, inBody []byte) (int, [
 	if request.Method == "POST" || request.Method == "PUT" {
 		request.Header.Set("Content-Type", "application/json")
 	}
+	if f.Author != "" {
+		request.Header.Set(apiserver.AuthorHeader, f.Author)
+	}
 
 	// Log after the call to m.do() so that the full hostname is present
 	defer glog.V(2).Infof("%s", toCurl(request, string(inBody)))
@@ -93,6 +98,8 @@ type Client interface {
 	UpdateConfig(Key, apiserver.Config) error
 	DeleteConfig(Key) error
 	ListConfig(string, string) ([]apiserver.Config, error)
+	History(Key) ([]apiserver.Revision, error)
+	Rollback(Key, string) (*apiserver.Revision, error)
 	Version() (*version.BuildInfo, error)
 }
 
@@ -103,7 +110,16 @@ func NewConfigClient(rr RESTRequester) *ConfigClient {
 }
 
 func (m *ConfigClient) doConfigCRUD(key Key, method string, inBody []byte) ([]byte, error) {
+	return m.doConfigRequest(key, "", method, inBody)
+}
+
+// doConfigRequest sends a request for a config resource or for an action on
+// the resource if the action is not empty
+func (m *ConfigClient) doConfigRequest(key Key, action, method string, inBody []byte) ([]byte, error) {
 	uriSuffix := fmt.Sprintf("config/%v/%v/%v", key.Kind, key.Namespace, key.Name)
+	if action != "" {
+		uriSuffix += "/" + action
+	}
 	status, body, err := m.rr.Request(method, uriSuffix, inBody)
 	if err != nil {
 		return nil, err
@@ -184,6 +200,36 @@ func (m *ConfigClient) ListConfig(kind, namespace string) ([]apiserver.Config, e
 	return config, nil
 }
 
+// History retrieves the revision history of the configuration resource for the passed key
+func (m *ConfigClient) History(key Key) ([]apiserver.Revision, error) {
+	body, err := m.doConfigRequest(key, "history", http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []apiserver.Revision
+	if err := json.Unmarshal(body, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Rollback restores the configuration resource for the passed key to a revision in its history
+func (m *ConfigClient) Rollback(key Key, revision string) (*apiserver.Revision, error) {
+	bodyIn, err := json.Marshal(apiserver.Rollback{Revision: revision})
+	if err != nil {
+		return nil, err
+	}
+	body, err := m.doConfigRequest(key, "rollback", http.MethodPost, bodyIn)
+	if err != nil {
+		return nil, err
+	}
+	out := &apiserver.Revision{}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Version returns the apiserver version.
 func (m *ConfigClient) Version() (*version.BuildInfo, error) {
 	status, body, err := m.rr.Request(http.MethodGet, "version", nil)
@@ -271,3 +271,65 @@ func TestGetAddUpdateDeleteListConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestHistoryRollback(t *testing.T) {
+	revisions := []apiserver.Revision{
+		{Revision: "1", Operation: "add", Author: "alice", Spec: "spec"},
+		{Revision: "2", Operation: "update", Author: "bob", Spec: "spec2", Previous: "spec"},
+	}
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if author := r.Header.Get(apiserver.AuthorHeader); author != "carol" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var out interface{} = revisions
+		if r.Method == http.MethodPost {
+			rollback := apiserver.Rollback{}
+			if err := json.NewDecoder(r.Body).Decode(&rollback); err != nil || rollback.Revision != "1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			out = apiserver.Revision{Revision: "3", Operation: "update", Author: "carol", Spec: "spec"}
+		}
+		body, _ := json.Marshal(out)
+		_, _ = w.Write(body)
+	}))
+	defer ts.Close()
+
+	client := NewConfigClient(&BasicHTTPRequester{
+		BaseURL: ts.URL,
+		Client:  &http.Client{Timeout: 1 * time.Second},
+		Author:  "carol",
+	})
+	key := Key{Name: "name", Namespace: "namespace", Kind: "route-rule"}
+
+	got, err := client.History(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, revisions) {
+		t.Errorf("wanted revisions: %+v, but received: %+v", revisions, got)
+	}
+
+	revision, err := client.Rollback(key, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision.Revision != "3" || revision.Author != "carol" {
+		t.Errorf("received unexpected rollback revision: %+v", revision)
+	}
+	if _, err = client.Rollback(key, "4"); err == nil {
+		t.Error("expected error for an unknown revision")
+	}
+
+	want := []string{
+		"GET /config/route-rule/namespace/name/history",
+		"POST /config/route-rule/namespace/name/rollback",
+		"POST /config/route-rule/namespace/name/rollback",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("wanted requests: %v, but received: %v", want, paths)
+	}
+}
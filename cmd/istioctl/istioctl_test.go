@@ -87,6 +87,29 @@ func (st *StubClient) ListConfig(string, string) ([]apiserver.Config, error) {
 	return res, nil
 }
 
+func (st *StubClient) History(key proxy.Key) ([]apiserver.Revision, error) {
+	if st.Error != nil {
+		return nil, st.Error
+	}
+	if _, ok := st.KeyConfigMap[key]; !ok {
+		return nil, fmt.Errorf("received unexpected key: %v", key)
+	}
+	return []apiserver.Revision{
+		{Revision: "1", Operation: "add", Author: "alice", Spec: map[string]interface{}{"precedence": 1}},
+		{Operation: "delete", Author: "bob", Previous: map[string]interface{}{"precedence": 1}},
+	}, nil
+}
+
+func (st *StubClient) Rollback(key proxy.Key, revision string) (*apiserver.Revision, error) {
+	if st.Error != nil {
+		return nil, st.Error
+	}
+	if _, ok := st.KeyConfigMap[key]; !ok || revision != "1" {
+		return nil, fmt.Errorf("received unexpected key: %v at revision %q", key, revision)
+	}
+	return &apiserver.Revision{Revision: "3", Operation: "add"}, nil
+}
+
 func (st *StubClient) Version() (*version.BuildInfo, error) {
 	return &version.BuildInfo{
 		Version:       "StubClient version",
@@ -342,6 +365,51 @@ func TestCreateUpdateDeleteGet(t *testing.T) {
 			wantError: true,
 			outFormat: "short",
 		},
+		{
+			name:            "TestHistory",
+			command:         "history",
+			arg:             []string{"route-rule", "test-v1"},
+			configKeyMapReq: true,
+			outFormat:       "short",
+		},
+		{
+			name:            "TestHistoryYAML",
+			command:         "history",
+			arg:             []string{"route-rule", "test-v1"},
+			configKeyMapReq: true,
+			outFormat:       "yaml",
+		},
+		{
+			name:      "TestHistoryNoName",
+			command:   "history",
+			arg:       []string{"route-rule"},
+			wantError: true,
+		},
+		{
+			name:      "TestHistoryPassesBackErrors",
+			command:   "history",
+			arg:       []string{"route-rule", "test-v1"},
+			wantError: true,
+			outFormat: "short",
+		},
+		{
+			name:            "TestRollback",
+			command:         "rollback",
+			arg:             []string{"route-rule", "test-v1", "1"},
+			configKeyMapReq: true,
+		},
+		{
+			name:      "TestRollbackNoRevision",
+			command:   "rollback",
+			arg:       []string{"route-rule", "test-v1"},
+			wantError: true,
+		},
+		{
+			name:      "TestRollbackPassesBackErrors",
+			command:   "rollback",
+			arg:       []string{"route-rule", "test-v1", "1"},
+			wantError: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -369,6 +437,10 @@ func TestCreateUpdateDeleteGet(t *testing.T) {
 			err = deleteCmd.RunE(deleteCmd, c.arg)
 		case "get":
 			err = getCmd.RunE(getCmd, c.arg)
+		case "history":
+			err = historyCmd.RunE(historyCmd, c.arg)
+		case "rollback":
+			err = rollbackCmd.RunE(rollbackCmd, c.arg)
 		}
 		if err != nil && !c.wantError {
 			t.Fatalf("%v: %v", c.name, err)
@@ -385,3 +457,19 @@ func TestVersions(t *testing.T) {
 		t.Errorf("istioctl version failed: %v", err)
 	}
 }
+
+func TestSetupNamespace(t *testing.T) {
+	namespace = "default"
+	if err := setup("route-rules", "test-v1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := (proxy.Key{Kind: "route-rule", Name: "test-v1", Namespace: "default"}); key != want {
+		t.Errorf("got key %+v, want %+v", key, want)
+	}
+	if err := setup("route-rule", "test-v1", "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	if key.Namespace != "team-a" {
+		t.Errorf("got namespace %q, want the namespace of the config", key.Namespace)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/ghodss/yaml"
@@ -49,13 +50,18 @@ const (
 type k8sRESTRequester struct {
 	namespace string
 	service   string
+	author    string
 	client    *kube.Client
 }
 
 // Request wraps Kubernetes specific requester to provide the proper
 // namespace and service names.a
 func (rr *k8sRESTRequester) Request(method, path string, inBody []byte) (int, []byte, error) {
-	return rr.client.Request(rr.namespace, rr.service, method, path, inBody)
+	var header map[string]string
+	if rr.author != "" {
+		header = map[string]string{apiserver.AuthorHeader: rr.author}
+	}
+	return rr.client.Request(rr.namespace, rr.service, method, path, inBody, header)
 }
 
 func kubeClientFromConfig(kubeconfig string) (*kube.Client, error) {
@@ -91,6 +97,12 @@ var (
 	// output format (yaml or short)
 	outputFormat string
 
+	// list the configuration objects in all namespaces
+	allNamespaces bool
+
+	// author of the configuration changes
+	author string
+
 	key    proxy.Key
 	schema model.ProtoSchema
 
@@ -133,12 +145,14 @@ istioctl mixer command documentation.
 					client:    client,
 					namespace: istioNamespace,
 					service:   istioConfigAPIService,
+					author:    author,
 				})
 			} else {
 				apiClient = proxy.NewConfigClient(&proxy.BasicHTTPRequester{
 					BaseURL: istioConfigAPIService,
 					Client:  &http.Client{Timeout: 60 * time.Second},
 					Version: kube.IstioResourceVersion,
+					Author:  author,
 				})
 			}
 
@@ -167,7 +181,7 @@ istioctl create -f example-routing.yaml
 				return errors.New("nothing to create")
 			}
 			for _, config := range varr {
-				if err = setup(config.Type, config.Name); err != nil {
+				if err = setup(config.Type, config.Name, config.Namespace); err != nil {
 					return err
 				}
 				err = apiClient.AddConfig(key, config)
@@ -200,7 +214,7 @@ istioctl replace -f example-routing.yaml
 				return errors.New("nothing to replace")
 			}
 			for _, config := range varr {
-				if err = setup(config.Type, config.Name); err != nil {
+				if err = setup(config.Type, config.Name, config.Namespace); err != nil {
 					return err
 				}
 				err = apiClient.UpdateConfig(key, config)
@@ -221,6 +235,9 @@ istioctl replace -f example-routing.yaml
 # List all route rules
 istioctl get route-rules
 
+# List the route rules in all namespaces
+istioctl get route-rules --all-namespaces
+
 # List all destination policies
 istioctl get destination-policies
 
@@ -235,7 +252,7 @@ istioctl get route-rule productpage-default
 			}
 
 			if len(args) > 1 {
-				if err := setup(args[0], args[1]); err != nil {
+				if err := setup(args[0], args[1], ""); err != nil {
 					c.Println(c.UsageString())
 					return err
 				}
@@ -254,10 +271,13 @@ istioctl get route-rule productpage-default
 				}
 				fmt.Print(string(out))
 			} else {
-				if err := setup(args[0], ""); err != nil {
+				if err := setup(args[0], "", ""); err != nil {
 					c.Println(c.UsageString())
 					return err
 				}
+				if allNamespaces {
+					key.Namespace = ""
+				}
 				glog.V(2).Infof("Getting multiple configs of kind %v in namespace %v", key.Kind, key.Namespace)
 				configList, err := apiClient.ListConfig(key.Kind, key.Namespace)
 				if err != nil {
@@ -305,7 +325,7 @@ istioctl delete route-rule productpage-default
 				}
 				var errs error
 				for i := 1; i < len(args); i++ {
-					if err := setup(args[0], args[i]); err != nil {
+					if err := setup(args[0], args[i], ""); err != nil {
 						// If the user specified an invalid rule kind on the CLI,
 						// don't keep processing -- it's probably a typo.
 						return err
@@ -334,7 +354,7 @@ istioctl delete route-rule productpage-default
 			}
 			var errs error
 			for _, v := range varr {
-				if err = setup(v.Type, v.Name); err != nil {
+				if err = setup(v.Type, v.Name, v.Namespace); err != nil {
 					errs = multierror.Append(errs, err)
 				} else {
 					if err = apiClient.DeleteConfig(key); err != nil {
@@ -349,6 +369,83 @@ istioctl delete route-rule productpage-default
 		},
 	}
 
+	historyCmd = &cobra.Command{
+		Use:   "history <type> <name>",
+		Short: "Display the revision history of a policy or rule",
+		Long: `
+Display the revision history of a policy or rule.
+
+The history is kept in memory by the Istio API server. It lists only the
+changes made through the API server replica that serves the request since
+that replica started.
+`,
+		Example: `
+# List the revisions of the rule productpage-default
+istioctl history route-rule productpage-default
+
+# Display the content of the revisions
+istioctl history route-rule productpage-default -o yaml
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				c.Println(c.UsageString())
+				return fmt.Errorf("provide configuration type and name")
+			}
+			if err := setup(args[0], args[1], ""); err != nil {
+				c.Println(c.UsageString())
+				return err
+			}
+			glog.V(2).Infof("Getting config history with key: %+v", key)
+			revisions, err := apiClient.History(key)
+			if err != nil {
+				return err
+			}
+
+			var outputters = map[string](func([]apiserver.Revision) error){
+				"yaml":  printHistoryYamlOutput,
+				"short": printHistoryShortOutput,
+			}
+			if outputFunc, ok := outputters[outputFormat]; ok {
+				return outputFunc(revisions)
+			}
+			return fmt.Errorf("unknown output format %v. Types are yaml|short", outputFormat)
+		},
+	}
+
+	rollbackCmd = &cobra.Command{
+		Use:   "rollback <type> <name> <revision>",
+		Short: "Restore a policy or rule to a revision in its history",
+		Long: `
+Restore a policy or rule to a revision in its history.
+
+The revision must be in the history of the Istio API server replica that
+serves the request (see "istioctl history"). The rollback fails if the policy
+or rule has changed since its last revision in that history.
+`,
+		Example: `
+# Restore the rule productpage-default to its content at revision 1234
+istioctl rollback route-rule productpage-default 1234
+`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				c.Println(c.UsageString())
+				return fmt.Errorf("provide configuration type, name and revision")
+			}
+			if err := setup(args[0], args[1], ""); err != nil {
+				c.Println(c.UsageString())
+				return err
+			}
+			glog.V(2).Infof("Rolling back config with key: %+v to revision %v", key, args[2])
+			revision, err := apiClient.Rollback(key, args[2])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Rolled back config: %v %v to revision %v (new revision %v)\n",
+				args[0], args[1], args[2], revision.Revision)
+			return nil
+		},
+	}
+
 	apiVersionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Display version information",
@@ -386,6 +483,8 @@ func init() {
 		"Name of Istio config service. When --kube=false this sets the address of the config service")
 	rootCmd.PersistentFlags().BoolVar(&useKubeRequester, "kube", true,
 		"Use Kubernetes client to send API requests to the config service")
+	rootCmd.PersistentFlags().StringVar(&author, "author", os.Getenv("USER"),
+		"Author of the configuration changes recorded in the revision history")
 
 	postCmd.PersistentFlags().StringVarP(&file, "file", "f", "",
 		"Input file with the content of the configuration objects (if not set, command reads from the standard input)")
@@ -394,6 +493,9 @@ func init() {
 
 	getCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "short",
 		"Output format. One of:yaml|short")
+	getCmd.PersistentFlags().BoolVar(&allNamespaces, "all-namespaces", false,
+		"List the policies and rules in all namespaces")
+	historyCmd.PersistentFlags().AddFlag(getCmd.PersistentFlags().Lookup("output"))
 
 	cmd.AddFlags(rootCmd)
 
@@ -401,6 +503,8 @@ func init() {
 	rootCmd.AddCommand(putCmd)
 	rootCmd.AddCommand(getCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(rollbackCmd)
 	rootCmd.AddCommand(apiVersionCmd)
 }
 
@@ -413,7 +517,8 @@ func main() {
 // Set the schema, key, and namespace
 // The schema is based on the kind (for example "route-rule" or "destination-policy")
 // name represents the name of an instance
-func setup(kind, name string) error {
+// ns is the namespace of the instance, defaulting to the --namespace flag
+func setup(kind, name, ns string) error {
 	var singularForm = map[string]string{
 		"route-rules":          "route-rule",
 		"destination-policies": "destination-policy",
@@ -430,11 +535,15 @@ func setup(kind, name string) error {
 			kind, strings.Join(model.IstioConfigTypes.Types(), ", "))
 	}
 
+	if ns == "" {
+		ns = namespace
+	}
+
 	// set the config key
 	key = proxy.Key{
 		Kind:      kind,
 		Name:      name,
-		Namespace: namespace,
+		Namespace: ns,
 	}
 
 	return nil
@@ -474,10 +583,14 @@ func readInputs() ([]apiserver.Config, error) {
 	return varr, nil
 }
 
-// Print a simple list of names
+// Print a simple list of names, qualified by the namespace when listing all namespaces
 func printShortOutput(configList []apiserver.Config) error {
 	for _, c := range configList {
-		fmt.Printf("%v\n", c.Name)
+		if allNamespaces {
+			fmt.Printf("%v\n", model.NamespacedKey(c.Namespace, c.Name))
+		} else {
+			fmt.Printf("%v\n", c.Name)
+		}
 	}
 
 	return nil
@@ -501,7 +614,11 @@ func printYamlOutput(configList []apiserver.Config) error {
 		} else {
 			fmt.Printf("type: %s\n", c.Type)
 			fmt.Printf("name: %s\n", c.Name)
-			fmt.Printf("namespace: %s\n", namespace)
+			ns := c.Namespace
+			if ns == "" {
+				ns = namespace
+			}
+			fmt.Printf("namespace: %s\n", ns)
 			fmt.Println("spec:")
 			lines := strings.Split(string(out), "\n")
 			for _, line := range lines {
@@ -516,6 +633,43 @@ func printYamlOutput(configList []apiserver.Config) error {
 	return retVal
 }
 
+// Print a table of the revisions
+func printHistoryShortOutput(revisions []apiserver.Revision) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tOPERATION\tAUTHOR\tTIMESTAMP")
+	for _, r := range revisions {
+		revision, author := r.Revision, r.Author
+		if revision == "" {
+			revision = "-"
+		}
+		if author == "" {
+			author = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", revision, r.Operation, author, r.Timestamp.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// Print the revisions with their content as YAML
+func printHistoryYamlOutput(revisions []apiserver.Revision) error {
+	var retVal error
+	for _, r := range revisions {
+		data, err := json.Marshal(r)
+		if err != nil {
+			retVal = multierror.Append(retVal, err)
+			continue
+		}
+		out, err := yaml.JSONToYAML(data)
+		if err != nil {
+			retVal = multierror.Append(retVal, err)
+			continue
+		}
+		fmt.Print(string(out))
+		fmt.Println("---")
+	}
+	return retVal
+}
+
 func printInfo(info version.BuildInfo, showKubeInjectInfo bool) {
 	fmt.Printf("Version: %v\n", info.Version)
 	fmt.Printf("GitRevision: %v\n", info.GitRevision)
@@ -17,6 +17,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -25,10 +26,14 @@ import (
 	"github.com/spf13/cobra"
 
 	proxyconfig "istio.io/api/proxy/v1/config"
+	"istio.io/pilot/adapter/config/history"
 	"istio.io/pilot/apiserver"
 	"istio.io/pilot/cmd"
 	"istio.io/pilot/cmd/version"
 	"istio.io/pilot/model"
+	"istio.io/pilot/platform/aggregate"
+	"istio.io/pilot/platform/consul"
+	"istio.io/pilot/platform/file"
 	"istio.io/pilot/platform/kube"
 	"istio.io/pilot/proxy"
 	"istio.io/pilot/proxy/envoy"
@@ -43,11 +48,30 @@ type args struct {
 	passthrough   []int
 	apiserverPort int
 
+	// number of revisions kept in the config history per object
+	historyLimit int
+
+	// service registries in the order of priority
+	registries    []string
+	registryFile  string
+	consulOptions consul.ControllerOptions
+
+	// remote Kubernetes clusters as "<cluster ID>=<kubeconfig>" pairs
+	remoteClusters []string
+	configCluster  string
+
 	// ingress sync mode is set to off by default
 	controllerOptions kube.ControllerOptions
 	discoveryOptions  envoy.DiscoveryServiceOptions
 }
 
+// Service registries supported by the discovery service
+const (
+	kubernetesRegistry = "Kubernetes"
+	fileRegistry       = "File"
+	consulRegistry     = "Consul"
+)
+
 var (
 	flags  args
 	client *kube.Client
@@ -69,8 +93,11 @@ var (
 			if err != nil {
 				return multierror.Prefix(err, "failed to connect to Kubernetes API.")
 			}
-			if err = client.RegisterResources(); err != nil {
-				return multierror.Prefix(err, "failed to register Third-Party Resources.")
+			// config resources are registered only in the cluster storing the configuration
+			if flags.configCluster == "" || flags.configCluster == flags.controllerOptions.ClusterID {
+				if err = client.RegisterResources(); err != nil {
+					return multierror.Prefix(err, "failed to register Third-Party Resources.")
+				}
 			}
 
 			// set values from environment variables
@@ -100,19 +127,30 @@ var (
 		Use:   "discovery",
 		Short: "Start Istio proxy discovery service",
 		RunE: func(c *cobra.Command, args []string) (err error) {
-			controller := kube.NewController(client, mesh, flags.controllerOptions)
+			clusters, configController, err := buildClusters()
+			if err != nil {
+				return err
+			}
+			registry, err := buildServiceRegistry(kube.NewMultiClusterController(clusters...))
+			if err != nil {
+				return err
+			}
 			context := &proxy.Context{
-				Discovery:  controller,
-				Accounts:   controller,
-				Config:     model.MakeIstioStore(controller),
+				Discovery:  registry,
+				Accounts:   registry,
+				Config:     model.MakeIstioStore(configController),
 				MeshConfig: mesh,
 			}
-			discovery, err := envoy.NewDiscoveryService(controller, controller, context, flags.discoveryOptions)
+			discovery, err := envoy.NewDiscoveryService(registry, configController, context, flags.discoveryOptions)
 			if err != nil {
 				return fmt.Errorf("failed to create discovery service: %v", err)
 			}
 			stop := make(chan struct{})
-			go controller.Run(stop)
+			// the Kubernetes controllers are run by the registry if it is listed
+			if !hasRegistry(kubernetesRegistry) {
+				go configController.Run(stop)
+			}
+			go registry.Run(stop)
 			go discovery.Run()
 			cmd.WaitSignal(stop)
 			return
@@ -127,7 +165,7 @@ var (
 			apiserver := apiserver.NewAPI(apiserver.APIServiceOptions{
 				Version:  kube.IstioResourceVersion,
 				Port:     flags.apiserverPort,
-				Registry: controller,
+				Registry: history.Make(controller, flags.controllerOptions.Namespace, flags.historyLimit),
 			})
 			stop := make(chan struct{})
 			go controller.Run(stop)
@@ -156,7 +194,7 @@ var (
 				UID:              fmt.Sprintf("kubernetes://%s.%s", flags.podName, flags.controllerOptions.Namespace),
 				PassthroughPorts: flags.passthrough,
 			}
-			w, err := envoy.NewWatcher(controller, controller, context)
+			w, err := envoy.NewWatcher(controller, context)
 			if err != nil {
 				return
 			}
@@ -214,13 +252,33 @@ func init() {
 
 	discoveryCmd.PersistentFlags().IntVar(&flags.discoveryOptions.Port, "port", 8080,
 		"Discovery service port")
+	discoveryCmd.PersistentFlags().IntVar(&flags.discoveryOptions.GrpcPort, "grpcPort", 0,
+		"Aggregated discovery service gRPC port for Envoy v2 API clients, disabled if 0")
 	discoveryCmd.PersistentFlags().BoolVar(&flags.discoveryOptions.EnableProfiling, "profile", true,
 		"Enable profiling via web interface host:port/debug/pprof")
 	discoveryCmd.PersistentFlags().BoolVar(&flags.discoveryOptions.EnableCaching, "discovery_cache", true,
 		"Enable caching discovery service responses")
+	discoveryCmd.PersistentFlags().StringSliceVar(&flags.registries, "registries", []string{kubernetesRegistry},
+		fmt.Sprintf("Comma separated list of service registries in the order of priority (%s, %s, %s)",
+			kubernetesRegistry, fileRegistry, consulRegistry))
+	discoveryCmd.PersistentFlags().StringVar(&flags.registryFile, "registryFile", "",
+		"YAML or JSON file declaring the services of the File registry")
+	discoveryCmd.PersistentFlags().StringVar(&flags.consulOptions.Address, "consulAddress", "http://127.0.0.1:8500",
+		"Address of the Consul HTTP API for the Consul registry")
+	discoveryCmd.PersistentFlags().StringVar(&flags.consulOptions.Datacenter, "consulDatacenter", "",
+		"Consul datacenter, defaults to the datacenter of the agent")
+	discoveryCmd.PersistentFlags().StringVar(&flags.controllerOptions.ClusterID, "clusterID", "",
+		"Identifier of the Kubernetes cluster in the service instances")
+	discoveryCmd.PersistentFlags().StringSliceVar(&flags.remoteClusters, "remoteClusters", nil,
+		"Comma separated list of <cluster ID>=<kubeconfig> pairs of Kubernetes clusters merged into the "+
+			"Kubernetes registry")
+	discoveryCmd.PersistentFlags().StringVar(&flags.configCluster, "configCluster", "",
+		"Identifier of the Kubernetes cluster storing the Istio configuration, defaults to the local cluster")
 
 	apiserverCmd.PersistentFlags().IntVar(&flags.apiserverPort, "port", 8081,
 		"Config API service port")
+	apiserverCmd.PersistentFlags().IntVar(&flags.historyLimit, "historyLimit", 10,
+		"Number of revisions kept in the history of each config object, 0 keeps all revisions")
 
 	proxyCmd.PersistentFlags().StringVar(&flags.ipAddress, "ipAddress", "",
 		"IP address. If not provided uses ${POD_IP} environment variable.")
@@ -242,6 +300,129 @@ func init() {
 	rootCmd.AddCommand(version.VersionCmd)
 }
 
+// buildClusters creates the controllers of the local and remote Kubernetes
+// clusters and selects the controller of the cluster storing the
+// configuration. Only the config cluster registers and watches the config
+// resources; the other clusters contribute services and instances.
+func buildClusters() ([]*kube.Controller, *kube.Controller, error) {
+	configID := flags.configCluster
+	if configID == "" {
+		configID = flags.controllerOptions.ClusterID
+	}
+
+	// the shared client registers the config resources of the local cluster
+	var config *kube.Controller
+	local := client
+	isConfig := configID == flags.controllerOptions.ClusterID
+	if !isConfig {
+		var err error
+		if local, err = kube.NewClient(flags.kubeconfig, nil, flags.controllerOptions.Namespace); err != nil {
+			return nil, nil, multierror.Prefix(err, "failed to connect to Kubernetes API.")
+		}
+	}
+	clusters := []*kube.Controller{buildClusterController(local, flags.controllerOptions, isConfig)}
+	if isConfig {
+		config = clusters[0]
+	}
+
+	for _, remote := range flags.remoteClusters {
+		parts := strings.SplitN(remote, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, nil, fmt.Errorf("invalid remote cluster %q, expecting <cluster ID>=<kubeconfig>", remote)
+		}
+		id, kubeconfig := parts[0], parts[1]
+		var kinds model.ConfigDescriptor
+		if id == configID {
+			kinds = model.IstioConfigTypes
+		}
+		remoteClient, err := kube.NewClient(kubeconfig, kinds, flags.controllerOptions.Namespace)
+		if err != nil {
+			return nil, nil, multierror.Prefix(err, fmt.Sprintf("failed to connect to cluster %s.", id))
+		}
+		if id == configID {
+			if err = remoteClient.RegisterResources(); err != nil {
+				return nil, nil, multierror.Prefix(err, fmt.Sprintf("failed to register Third-Party Resources in %s.", id))
+			}
+		}
+		options := flags.controllerOptions
+		options.ClusterID = id
+		ctl := buildClusterController(remoteClient, options, id == configID)
+		clusters = append(clusters, ctl)
+		if id == configID {
+			config = ctl
+		}
+	}
+	if config == nil {
+		return nil, nil, fmt.Errorf("unknown config cluster %q", flags.configCluster)
+	}
+	return clusters, config, nil
+}
+
+// buildClusterController creates the controller of a Kubernetes cluster.
+// Clusters other than the config cluster do not watch ingress resources.
+func buildClusterController(kubeClient *kube.Client, options kube.ControllerOptions, isConfig bool) *kube.Controller {
+	if isConfig {
+		return kube.NewController(kubeClient, mesh, options)
+	}
+	serviceMesh := *mesh
+	serviceMesh.IngressControllerMode = proxyconfig.ProxyMeshConfig_OFF
+	return kube.NewController(kubeClient, &serviceMesh, options)
+}
+
+// buildServiceRegistry aggregates the service registries selected by the flags.
+// The Kubernetes registry shares the cluster controllers with the config store.
+func buildServiceRegistry(controller *kube.MultiClusterController) (*aggregate.Controller, error) {
+	registries := make([]aggregate.Registry, 0, len(flags.registries))
+	for _, name := range flags.registries {
+		switch name {
+		case kubernetesRegistry:
+			registries = append(registries, aggregate.Registry{
+				Name:             name,
+				ServiceDiscovery: controller,
+				ServiceAccounts:  controller,
+				Controller:       controller,
+			})
+		case fileRegistry:
+			if flags.registryFile == "" {
+				return nil, fmt.Errorf("%s registry requires --registryFile", name)
+			}
+			ctl, err := file.NewController(flags.registryFile)
+			if err != nil {
+				return nil, multierror.Prefix(err, "failed to load the service registry file.")
+			}
+			registries = append(registries, aggregate.Registry{
+				Name:             name,
+				ServiceDiscovery: ctl,
+				ServiceAccounts:  ctl,
+				Controller:       ctl,
+			})
+		case consulRegistry:
+			ctl := consul.NewController(flags.consulOptions)
+			registries = append(registries, aggregate.Registry{
+				Name:             name,
+				ServiceDiscovery: ctl,
+				ServiceAccounts:  ctl,
+				Controller:       ctl,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported service registry %q", name)
+		}
+	}
+	if len(registries) == 0 {
+		return nil, fmt.Errorf("at least one service registry is required")
+	}
+	return aggregate.NewController(registries...), nil
+}
+
+func hasRegistry(name string) bool {
+	for _, registry := range flags.registries {
+		if registry == name {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		glog.Error(err)
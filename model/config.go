@@ -17,6 +17,7 @@ package model
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
@@ -25,17 +26,21 @@ import (
 )
 
 // Config is a configuration unit consisting of the type of configuration, the
-// key identifier that is unique per type, and the content represented as a
-// protobuf message.  The revision is optional, and if provided, identifies the
-// last update operation on the object.
+// key identifier that is unique per type and namespace, the namespace, and the
+// content represented as a protobuf message.  The revision is optional, and if
+// provided, identifies the last update operation on the object.
 type Config struct {
 	// Type is a short configuration name that matches the content message type
 	Type string
 
-	// Key is the type-dependent unique identifier for this config object, derived
-	// from its content
+	// Key is the type-dependent unique identifier for this config object within
+	// its namespace, derived from its content
 	Key string
 
+	// Namespace of the config object. Config stores that do not partition
+	// the configuration by namespace leave it empty.
+	Namespace string
+
 	// Revision is an opaque identifier for tracking updates to the config registry.
 	// The implementation may use a change index or a commit log for the revision.
 	// The config client should not make any assumptions about revisions and rely only on
@@ -59,12 +64,15 @@ type Config struct {
 // layer supports _GET_ (list), _PUT_ (update), _POST_ (create) and _DELETE_
 // semantics but does not guarantee any transactional semantics.
 //
-// Configuration objects possess a type property and a key property. The
-// configuration key is derived from the content of the config object and
-// uniquely identifies objects for a particular type. For example, if the
-// object schema contains metadata fields _name_ and _namespace_, the
-// configuration key may be defined as _namespace/name_.  Key definition is
-// provided as part of the config type definition.
+// Configuration objects possess a type property, a key property and a
+// namespace property. The configuration key is derived from the content of the
+// config object and uniquely identifies objects for a particular type within a
+// namespace. For example, if the object schema contains a metadata field
+// _name_, the configuration key may be defined as _name_, and two objects with
+// the same name may coexist in different namespaces.  Key definition is
+// provided as part of the config type definition.  An empty namespace in a
+// _GET_, _PUT_, _POST_ or _DELETE_ operation selects the default namespace of
+// the store.
 //
 // _PUT_, _POST_, and _DELETE_ are mutator operations. These operations are
 // asynchronous, and you might not see the effect immediately (e.g. _GET_ might
@@ -88,24 +96,38 @@ type ConfigStore interface {
 	// types and the protobuf encoding schema.
 	ConfigDescriptor() ConfigDescriptor
 
-	// Get retrieves a configuration element by a type and a key
-	Get(typ, key string) (config proto.Message, exists bool, revision string)
+	// Get retrieves a configuration element by a type, a key and a namespace
+	Get(typ, key, namespace string) (config proto.Message, exists bool, revision string)
 
-	// List returns objects by type
-	List(typ string) ([]Config, error)
+	// List returns objects by type in a namespace. Use NamespaceAll to list
+	// the objects in all namespaces.
+	List(typ, namespace string) ([]Config, error)
 
-	// Post creates a configuration object. If an object with the same key for
-	// the type already exists, the operation fails with no side effects.
-	Post(config proto.Message) (revision string, err error)
+	// Post creates a configuration object in a namespace. If an object with the
+	// same key for the type already exists in the namespace, the operation
+	// fails with no side effects.
+	Post(config proto.Message, namespace string) (revision string, err error)
 
 	// Put updates a configuration object in the store.  Put requires that the
 	// object has been created.  Revision prevents overriding a value that has
 	// been changed between prior _Get_ and _Put_ operation to achieve optimistic
 	// concurrency. This method returns a new revision if the operation succeeds.
-	Put(config proto.Message, oldRevision string) (newRevision string, err error)
+	Put(config proto.Message, namespace, oldRevision string) (newRevision string, err error)
+
+	// Delete removes an object from the store by key and namespace
+	Delete(typ, key, namespace string) error
+}
+
+// NamespaceAll selects the objects in all namespaces in a List operation
+const NamespaceAll = ""
 
-	// Delete removes an object from the store by key
-	Delete(typ, key string) error
+// NamespacedKey qualifies a configuration key with its namespace as
+// _namespace/key_, or returns the key if the namespace is empty
+func NamespacedKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + "/" + key
 }
 
 // ConfigStoreCache is a local fully-replicated cache of the config store.  The
@@ -132,6 +154,55 @@ type ConfigStoreCache interface {
 	Run(stop <-chan struct{})
 }
 
+// ConfigRevision records a mutation of a config object in the history of the
+// object
+type ConfigRevision struct {
+	// Revision assigned to the object by the mutation. Deletions leave the
+	// revision empty.
+	Revision string
+
+	// Event is the kind of mutation: creation, update or deletion
+	Event Event
+
+	// Author of the mutation, if known
+	Author string
+
+	// Timestamp of the mutation
+	Timestamp time.Time
+
+	// Content of the object after the mutation, or nil for a deletion
+	Content proto.Message
+
+	// Previous content of the object, or nil for a creation
+	Previous proto.Message
+}
+
+// ConfigHistory is a config store that records the mutations of the config
+// objects, and that restores the objects to their recorded revisions.
+//
+// Mutations through the embedded config store have no author. Use WithAuthor
+// to attribute the mutations to an author.
+type ConfigHistory interface {
+	ConfigStore
+
+	// WithAuthor returns a view of the store that records the author of the
+	// mutations
+	WithAuthor(author string) ConfigStore
+
+	// History returns the recorded mutations of a config object by a type, a
+	// key and a namespace, the oldest first. The history of deleted objects
+	// is retained.
+	History(typ, key, namespace string) ([]ConfigRevision, error)
+
+	// Rollback restores the content of a config object as of a revision in
+	// its history. The object is re-created if it has been deleted. The
+	// rollback is itself recorded as a mutation by the author, and the method
+	// returns the new revision of the object. The rollback fails with a
+	// RevisionConflictError if the object has changed since its last recorded
+	// mutation.
+	Rollback(typ, key, namespace, revision, author string) (newRevision string, err error)
+}
+
 // ConfigDescriptor defines the bijection between the short type name and its
 // fully qualified protobuf message name
 type ConfigDescriptor []ProtoSchema
@@ -188,13 +259,13 @@ func (descriptor ConfigDescriptor) GetByType(name string) (ProtoSchema, bool) {
 // IstioConfigStore is a specialized interface to access config store using
 // Istio configuration types
 type IstioConfigStore interface {
-	// RouteRules lists all routing rules
+	// RouteRules lists all routing rules in all namespaces by the namespaced key
 	RouteRules() map[string]*proxyconfig.RouteRule
 
-	// IngressRules lists all ingress rules
+	// IngressRules lists all ingress rules in all namespaces by the namespaced key
 	IngressRules() map[string]*proxyconfig.IngressRule
 
-	// DestinationPolicies lists all destination rules
+	// DestinationPolicies lists all destination rules ordered by namespace and key
 	DestinationPolicies() []*proxyconfig.DestinationPolicy
 
 	// RouteRulesBySource selects routing rules by source service instances.
@@ -203,7 +274,11 @@ type IstioConfigStore interface {
 	// The rules are sorted by precedence (high first) in a stable manner.
 	RouteRulesBySource(instances []*ServiceInstance) []*proxyconfig.RouteRule
 
-	// DestinationPolicy returns a policy for a service version.
+	// DestinationPolicy returns a policy for a service version. A policy with
+	// tags equal to the version tags is preferred over the first policy, in the
+	// order of DestinationPolicies, whose tag selector matches them. Version
+	// tags with set-based values describe several versions and only match
+	// policies with equal tags.
 	DestinationPolicy(destination string, tags Tags) *proxyconfig.DestinationVersionPolicy
 }
 
@@ -275,41 +350,43 @@ func MakeIstioStore(store ConfigStore) IstioConfigStore {
 
 func (i istioConfigStore) RouteRules() map[string]*proxyconfig.RouteRule {
 	out := make(map[string]*proxyconfig.RouteRule)
-	rs, err := i.List(RouteRule)
+	rs, err := i.List(RouteRule, NamespaceAll)
 	if err != nil {
 		glog.V(2).Infof("RouteRules => %v", err)
 	}
 	for _, r := range rs {
 		if rule, ok := r.Content.(*proxyconfig.RouteRule); ok {
-			out[r.Key] = rule
+			out[NamespacedKey(r.Namespace, r.Key)] = rule
 		}
 	}
 	return out
 }
 
+// MatchSource validates that the rule match predicate applies to source service instances
+func MatchSource(rule *proxyconfig.RouteRule, instances []*ServiceInstance) bool {
+	if rule.Match == nil {
+		return true
+	}
+	for _, instance := range instances {
+		// must match the source field if it is set
+		if rule.Match.Source != "" && rule.Match.Source != instance.Service.Hostname {
+			continue
+		}
+		// must match the tags field - the instance tags satisfy the rule tags selector
+		var tags Tags = rule.Match.SourceTags
+		if tags.Selector().Matches(instance.Tags) {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *istioConfigStore) RouteRulesBySource(instances []*ServiceInstance) []*proxyconfig.RouteRule {
 	rules := make([]Config, 0)
 	for key, rule := range i.RouteRules() {
-		// validate that rule match predicate applies to source service instances
-		if rule.Match != nil {
-			found := false
-			for _, instance := range instances {
-				// must match the source field if it is set
-				if rule.Match.Source != "" && rule.Match.Source != instance.Service.Hostname {
-					continue
-				}
-				// must match the tags field - the rule tags are a subset of the instance tags
-				var tags Tags = rule.Match.SourceTags
-				if tags.SubsetOf(instance.Tags) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
+		if MatchSource(rule, instances) {
+			rules = append(rules, Config{Key: key, Content: rule})
 		}
-		rules = append(rules, Config{Key: key, Content: rule})
 	}
 	// sort by high precedence first, key string second (keys are unique)
 	sort.Slice(rules, func(i, j int) bool {
@@ -328,13 +405,13 @@ func (i *istioConfigStore) RouteRulesBySource(instances []*ServiceInstance) []*p
 
 func (i *istioConfigStore) IngressRules() map[string]*proxyconfig.IngressRule {
 	out := make(map[string]*proxyconfig.IngressRule)
-	rs, err := i.List(IngressRule)
+	rs, err := i.List(IngressRule, NamespaceAll)
 	if err != nil {
 		glog.V(2).Infof("IngressRules => %v", err)
 	}
 	for _, r := range rs {
 		if rule, ok := r.Content.(*proxyconfig.IngressRule); ok {
-			out[r.Key] = rule
+			out[NamespacedKey(r.Namespace, r.Key)] = rule
 		}
 	}
 	return out
@@ -342,10 +419,17 @@ func (i *istioConfigStore) IngressRules() map[string]*proxyconfig.IngressRule {
 
 func (i *istioConfigStore) DestinationPolicies() []*proxyconfig.DestinationPolicy {
 	out := make([]*proxyconfig.DestinationPolicy, 0)
-	rs, err := i.List(DestinationPolicy)
+	rs, err := i.List(DestinationPolicy, NamespaceAll)
 	if err != nil {
 		glog.V(2).Infof("DestinationPolicies => %v", err)
 	}
+	// the registries list configs in no particular order
+	sort.Slice(rs, func(a, b int) bool {
+		if rs[a].Namespace != rs[b].Namespace {
+			return rs[a].Namespace < rs[b].Namespace
+		}
+		return rs[a].Key < rs[b].Key
+	})
 	for _, r := range rs {
 		if rule, ok := r.Content.(*proxyconfig.DestinationPolicy); ok {
 			out = append(out, rule)
@@ -356,14 +440,19 @@ func (i *istioConfigStore) DestinationPolicies() []*proxyconfig.DestinationPolic
 
 func (i *istioConfigStore) DestinationPolicy(destination string, tags Tags) *proxyconfig.DestinationVersionPolicy {
 	// TODO: optimize destination policy retrieval
+	exact := tags.Selector().isEquality()
+	var selected *proxyconfig.DestinationVersionPolicy
 	for _, value := range i.DestinationPolicies() {
 		if value.Destination == destination {
 			for _, policy := range value.Policy {
 				if tags.Equals(policy.Tags) {
 					return policy
 				}
+				if selected == nil && exact && Tags(policy.Tags).Selector().Matches(tags) {
+					selected = policy
+				}
 			}
 		}
 	}
-	return nil
+	return selected
 }
@@ -39,7 +39,7 @@ func TestKindMapKinds(t *testing.T) {
 }
 
 type testRegistry struct {
-	ctrl     gomock.Controller
+	ctrl     *gomock.Controller
 	mock     *MockConfigStore
 	registry IstioConfigStore
 }
@@ -48,6 +48,7 @@ func initTestRegistry(t *testing.T) *testRegistry {
 	ctrl := gomock.NewController(t)
 	mock := NewMockConfigStore(ctrl)
 	return &testRegistry{
+		ctrl: ctrl,
 		mock: mock,
 		registry: &istioConfigStore{
 			ConfigStore: mock,
@@ -194,7 +195,7 @@ func TestIstioRegistryRouteAndIngressRules(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		r.mock.EXPECT().List(RouteRule).Return(c.mockObjs, c.mockError)
+		r.mock.EXPECT().List(RouteRule, NamespaceAll).Return(c.mockObjs, c.mockError)
 		if got := r.registry.RouteRules(); !reflect.DeepEqual(got, c.want) {
 			t.Errorf("%v with RouteRule failed: \ngot %+vwant %+v", c.name, spew.Sdump(got), spew.Sdump(c.want))
 		}
@@ -222,7 +223,7 @@ func TestIstioRegistryRouteRulesBySource(t *testing.T) {
 		routeRule2SourceEmpty,
 	}
 
-	r.mock.EXPECT().List(RouteRule).Return(mockObjs, nil)
+	r.mock.EXPECT().List(RouteRule, NamespaceAll).Return(mockObjs, nil)
 	got := r.registry.RouteRulesBySource(instances)
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("Failed \ngot %+vwant %+v", spew.Sdump(got), spew.Sdump(want))
@@ -266,7 +267,7 @@ func TestIstioRegistryPolicies(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		r.mock.EXPECT().List(DestinationPolicy).Return(c.mockObjs, c.mockError)
+		r.mock.EXPECT().List(DestinationPolicy, NamespaceAll).Return(c.mockObjs, c.mockError)
 		if got := r.registry.DestinationPolicies(); !reflect.DeepEqual(makeSet(got), makeSet(c.want)) {
 			t.Errorf("%v failed: \ngot %+vwant %+v", c.name, spew.Sdump(got), spew.Sdump(c.want))
 		}
@@ -284,13 +285,71 @@ func TestIstioRegistryDestinationPolicies(t *testing.T) {
 		{Key: "baz", Content: dstPolicy4},
 	}
 
-	r.mock.EXPECT().List(DestinationPolicy).Return(mockObjs, nil)
+	r.mock.EXPECT().List(DestinationPolicy, NamespaceAll).Return(mockObjs, nil)
 	want := dstPolicy1.Policy[0]
 	if got := r.registry.DestinationPolicy(dstPolicy1.Destination, want.Tags); !reflect.DeepEqual(got, want) {
 		t.Errorf("Failed: \ngot %+vwant %+v", spew.Sdump(got), spew.Sdump(want))
 	}
 }
 
+func TestIstioRegistryDestinationPolicySelector(t *testing.T) {
+	r := initTestRegistry(t)
+	defer r.shutdown()
+
+	v1 := &proxyconfig.DestinationVersionPolicy{Tags: map[string]string{"version": "v1"}}
+	notV1 := &proxyconfig.DestinationVersionPolicy{Tags: map[string]string{"version": "!=v1"}}
+	policy := &proxyconfig.DestinationPolicy{
+		Destination: "foo",
+		Policy:      []*proxyconfig.DestinationVersionPolicy{notV1, v1},
+	}
+	mockObjs := []Config{{Key: "foo", Content: policy}}
+
+	cases := []struct {
+		tags Tags
+		want *proxyconfig.DestinationVersionPolicy
+	}{
+		{Tags{"version": "v1"}, v1},
+		{Tags{"version": "v2"}, notV1},
+		{Tags{"version": "!=v1"}, notV1},
+		{Tags{"version": "in(v2,v3)"}, nil},
+	}
+	for _, c := range cases {
+		r.mock.EXPECT().List(DestinationPolicy, NamespaceAll).Return(mockObjs, nil)
+		if got := r.registry.DestinationPolicy("foo", c.tags); got != c.want {
+			t.Errorf("DestinationPolicy(%v) => got %v, want %v", c.tags, got, c.want)
+		}
+	}
+}
+
+func TestIstioRegistryDestinationPolicyOrder(t *testing.T) {
+	r := initTestRegistry(t)
+	defer r.shutdown()
+
+	first := &proxyconfig.DestinationVersionPolicy{Tags: map[string]string{"version": "!=v1"}}
+	second := &proxyconfig.DestinationVersionPolicy{Tags: map[string]string{"version": "*"}}
+	third := &proxyconfig.DestinationVersionPolicy{Tags: map[string]string{"version": "in(v2,v3)"}}
+	policy := func(p *proxyconfig.DestinationVersionPolicy) *proxyconfig.DestinationPolicy {
+		return &proxyconfig.DestinationPolicy{Destination: "foo", Policy: []*proxyconfig.DestinationVersionPolicy{p}}
+	}
+
+	// the store lists the policies in an arbitrary order
+	mockObjs := []Config{
+		{Key: "b", Namespace: "default", Content: policy(third)},
+		{Key: "b", Namespace: "istio-system", Content: policy(second)},
+		{Key: "a", Namespace: "default", Content: policy(first)},
+	}
+	r.mock.EXPECT().List(DestinationPolicy, NamespaceAll).Return(mockObjs, nil)
+	if got := r.registry.DestinationPolicies(); len(got) != 3 ||
+		got[0].Policy[0] != first || got[1].Policy[0] != third || got[2].Policy[0] != second {
+		t.Errorf("DestinationPolicies() => got %v, want the policies ordered by namespace and key", got)
+	}
+
+	r.mock.EXPECT().List(DestinationPolicy, NamespaceAll).Return(mockObjs, nil)
+	if got := r.registry.DestinationPolicy("foo", Tags{"version": "v2"}); got != first {
+		t.Errorf("DestinationPolicy() => got %v, want %v", got, first)
+	}
+}
+
 func TestEventString(t *testing.T) {
 	cases := []struct {
 		in   Event
@@ -493,6 +552,14 @@ func TestTagsEquals(t *testing.T) {
 			b:    Tags{"a": "b"},
 			want: true,
 		},
+		{
+			a: Tags{"a": "b"},
+			b: Tags{"a": "b", "c": "d"},
+		},
+		{
+			a: Tags{"a": ""},
+			b: Tags{"b": ""},
+		},
 	}
 	for _, c := range cases {
 		if got := c.a.Equals(c.b); got != c.want {
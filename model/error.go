@@ -33,3 +33,20 @@ func (e *ItemNotFoundError) Error() string {
 	}
 	return fmt.Sprintf("item with key %+v not found", e.Key)
 }
+
+// RevisionConflictError is a typed error that should be used to identify when an item in the
+// configuration registry has changed since the expected revision. To overwrite the default error
+// message set the Msg field.
+type RevisionConflictError struct {
+	Key string
+	Msg string
+}
+
+// Error fulfills the basic Error interface for the RevisionConflictError
+// If a message is set it returns that otherwise it returns a default error including the key
+func (e *RevisionConflictError) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("item with key %+v has a conflicting revision", e.Key)
+}
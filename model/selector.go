@@ -0,0 +1,256 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// Operator is the relation between a tag and the values of a requirement
+type Operator string
+
+const (
+	// OpEquals requires the tag to have the value
+	OpEquals Operator = "="
+
+	// OpNotEquals requires the tag to be absent or to have another value
+	OpNotEquals Operator = "!="
+
+	// OpIn requires the tag to have one of the values
+	OpIn Operator = "in"
+
+	// OpNotIn requires the tag to be absent or to have none of the values
+	OpNotIn Operator = "notin"
+
+	// OpExists requires the tag to be present
+	OpExists Operator = "exists"
+
+	// OpDoesNotExist requires the tag to be absent
+	OpDoesNotExist Operator = "!"
+)
+
+// Requirement is a constraint on a single tag
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector is a conjunction of requirements on the tags of a service
+// instance. The empty selector matches all instances.
+//
+// A selector prints as a comma-separated list of requirements, similar to the
+// Kubernetes label selectors:
+//
+//	version=v1            the tag "version" is "v1"
+//	version!=v1           the tag "version" is absent or not "v1"
+//	version in (v1,v2)    the tag "version" is "v1" or "v2"
+//	version notin (v1,v2) the tag "version" is absent or neither "v1" nor "v2"
+//	canary                the tag "canary" is present
+//	!canary               the tag "canary" is absent
+//
+// Tags in rules express the same requirements with the set-based forms in
+// the tag values, see Tags.Selector.
+type Selector []Requirement
+
+// SelectorList is a disjunction of selectors. The empty list matches all
+// instances.
+type SelectorList []Selector
+
+// Matches is true if the tags satisfy the requirement
+func (r Requirement) Matches(tags Tags) bool {
+	value, exists := tags[r.Key]
+	switch r.Operator {
+	case OpEquals, OpIn:
+		return exists && contains(r.Values, value)
+	case OpNotEquals, OpNotIn:
+		return !exists || !contains(r.Values, value)
+	case OpExists:
+		return exists
+	case OpDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OpEquals, OpNotEquals:
+		return r.Key + string(r.Operator) + strings.Join(r.Values, ",")
+	case OpIn, OpNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case OpDoesNotExist:
+		return "!" + r.Key
+	}
+	return r.Key
+}
+
+// isEquality is true if all requirements of the selector require tag values
+func (s Selector) isEquality() bool {
+	for _, r := range s {
+		if r.Operator != OpEquals {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches is true if the tags satisfy all requirements of the selector
+func (s Selector) Matches(tags Tags) bool {
+	for _, r := range s {
+		if !r.Matches(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	out := make([]string, 0, len(s))
+	for _, r := range s {
+		out = append(out, r.String())
+	}
+	return strings.Join(out, ",")
+}
+
+// Matches is true if the tags satisfy one of the selectors or if the list is
+// empty
+func (list SelectorList) Matches(tags Tags) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s.Matches(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTagValue converts a tag of a rule to a requirement
+func parseTagValue(key, value string) Requirement {
+	switch {
+	case value == "*":
+		return Requirement{Key: key, Operator: OpExists}
+	case strings.HasPrefix(value, "!="):
+		return Requirement{Key: key, Operator: OpNotEquals, Values: []string{value[2:]}}
+	case strings.HasPrefix(value, "in(") && strings.HasSuffix(value, ")"):
+		return Requirement{Key: key, Operator: OpIn, Values: splitValues(value[3 : len(value)-1])}
+	case strings.HasPrefix(value, "notin(") && strings.HasSuffix(value, ")"):
+		return Requirement{Key: key, Operator: OpNotIn, Values: splitValues(value[6 : len(value)-1])}
+	}
+	return Requirement{Key: key, Operator: OpEquals, Values: []string{value}}
+}
+
+// Selector returns the requirements expressed by the tags of a rule. A tag
+// value may use a set-based form: "!=v1" excludes a value, "in(v1,v2)"
+// requires one of the values, "notin(v1,v2)" excludes the values, and "*"
+// requires the tag to be present with any value. Any other value requires
+// the tag to be equal to the value.
+func (t Tags) Selector() Selector {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make(Selector, 0, len(t))
+	for _, k := range keys {
+		out = append(out, parseTagValue(k, t[k]))
+	}
+	return out
+}
+
+// Selectors returns the selectors expressed by the tags in the collection
+func (tags TagsList) Selectors() SelectorList {
+	out := make(SelectorList, 0, len(tags))
+	for _, tag := range tags {
+		out = append(out, tag.Selector())
+	}
+	return out
+}
+
+// Matches returns true if the input tags satisfy the selector of one of the
+// tags in the collection or if the collection is empty
+func (tags TagsList) Matches(that Tags) bool {
+	return tags.Selectors().Matches(that)
+}
+
+// ValidateSelector ensures that the tags of a rule are well-formed, allowing
+// the set-based forms in the tag values
+func (t Tags) ValidateSelector() error {
+	var errs error
+	for k, v := range t {
+		if !tagRegexp.MatchString(k) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid tag key: %q", k))
+		}
+		r := parseTagValue(k, v)
+		if r.Operator == OpExists {
+			continue
+		}
+		if len(r.Values) == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid tag value: %q has no values", v))
+		}
+		for _, value := range r.Values {
+			if !tagRegexp.MatchString(value) {
+				errs = multierror.Append(errs, fmt.Errorf("invalid tag value: %q", v))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// splitTerms splits a string at the commas outside parentheses
+func splitTerms(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+func splitValues(s string) []string {
+	var out []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	v1 := Tags{"version": "v1", "app": "a"}
+	v2 := Tags{"version": "v2", "app": "a"}
+	canary := Tags{"version": "v3", "canary": "true"}
+	none := Tags{}
+
+	versions := []string{"v1", "v2"}
+	cases := []struct {
+		selector Selector
+		matches  []Tags
+		excludes []Tags
+	}{
+		{Selector{}, []Tags{v1, v2, canary, none}, nil},
+		{Selector{{Key: "version", Operator: OpEquals, Values: versions[:1]}}, []Tags{v1}, []Tags{v2, canary, none}},
+		{Selector{{Key: "version", Operator: OpNotEquals, Values: versions[:1]}}, []Tags{v2, canary, none}, []Tags{v1}},
+		{Selector{{Key: "version", Operator: OpIn, Values: versions}}, []Tags{v1, v2}, []Tags{canary, none}},
+		{Selector{{Key: "version", Operator: OpNotIn, Values: versions}}, []Tags{canary, none}, []Tags{v1, v2}},
+		{Selector{{Key: "canary", Operator: OpExists}}, []Tags{canary}, []Tags{v1, v2, none}},
+		{Selector{{Key: "canary", Operator: OpDoesNotExist}, {Key: "app", Operator: OpEquals, Values: []string{"a"}}},
+			[]Tags{v1, v2}, []Tags{canary, none}},
+	}
+	for _, c := range cases {
+		for _, tags := range c.matches {
+			if !c.selector.Matches(tags) {
+				t.Errorf("%q.Matches(%v) => got false", c.selector, tags)
+			}
+		}
+		for _, tags := range c.excludes {
+			if c.selector.Matches(tags) {
+				t.Errorf("%q.Matches(%v) => got true", c.selector, tags)
+			}
+		}
+	}
+}
+
+func TestTagsSelector(t *testing.T) {
+	v1 := Tags{"version": "v1"}
+	v2 := Tags{"version": "v2"}
+	v3 := Tags{"version": "v3"}
+
+	// all versions except v1
+	for _, value := range []string{"!=v1", "notin(v1)"} {
+		list := TagsList{{"version": value}}
+		if list.Matches(v1) || !list.Matches(v2) || !list.Matches(v3) {
+			t.Errorf("%v.Matches => want all versions except v1", list)
+		}
+	}
+
+	list := TagsList{{"version": "in(v1,v2)"}}
+	if !list.Matches(v1) || !list.Matches(v2) || list.Matches(v3) {
+		t.Errorf("%v.Matches => want v1 and v2", list)
+	}
+
+	list = TagsList{{"version": "*"}}
+	if !list.Matches(v1) || !list.Matches(v3) || list.Matches(Tags{"app": "a"}) {
+		t.Errorf("%v.Matches => want all tags with a version", list)
+	}
+
+	// exact tags keep the subset semantics
+	list = TagsList{{"version": "v1"}, {"version": "v3"}}
+	if !list.Matches(v1) || list.Matches(v2) || !list.Matches(v3) {
+		t.Errorf("%v.Matches => want v1 and v3", list)
+	}
+	if !(TagsList{}).Matches(v1) || !(TagsList{nil}).Matches(v1) {
+		t.Error("empty tags list must match all tags")
+	}
+
+	tags := ParseTagString("version=notin(v1,v2),app=a")
+	want := Tags{"version": "notin(v1,v2)", "app": "a"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("ParseTagString => got %v, want %v", tags, want)
+	}
+	if got := ParseTagString(tags.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTagString(%q) => got %v, want %v", tags.String(), got, want)
+	}
+}
+
+func TestValidateSelector(t *testing.T) {
+	valid := []Tags{
+		{"version": "v1"},
+		{"version": "!=v1"},
+		{"version": "in(v1,v2)"},
+		{"version": "notin(v1)", "app": "a"},
+		{"version": "*"},
+	}
+	for _, tags := range valid {
+		if err := tags.ValidateSelector(); err != nil {
+			t.Errorf("%v.ValidateSelector() => got error %v", tags, err)
+		}
+	}
+
+	invalid := []Tags{
+		{"version": "in()"},
+		{"version": "!=v 1"},
+		{"version": "notin(v1,v$2)"},
+		{"ver sion": "v1"},
+	}
+	for _, tags := range invalid {
+		if err := tags.ValidateSelector(); err == nil {
+			t.Errorf("%v.ValidateSelector() => expected error", tags)
+		}
+	}
+}
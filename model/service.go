@@ -159,6 +159,14 @@ type ServiceInstance struct {
 	Endpoint NetworkEndpoint `json:"endpoint,omitempty"`
 	Service  *Service        `json:"service,omitempty"`
 	Tags     Tags            `json:"tags,omitempty"`
+
+	// Cluster identifies the cluster running the instance when services
+	// span several clusters
+	Cluster string `json:"cluster,omitempty"`
+
+	// UID is the platform specific identifier of the workload running the
+	// instance, used as the identity of its proxy. Example: "kubernetes://my-pod.my-namespace"
+	UID string `json:"uid,omitempty"`
 }
 
 // ServiceDiscovery enumerates Istio service instances.
@@ -170,7 +178,8 @@ type ServiceDiscovery interface {
 	GetService(hostname string) (*Service, bool)
 
 	// Instances retrieves instances for a service and its ports that match
-	// any of the supplied tags. All instances match an empty tag list.
+	// any of the supplied tags. All instances match an empty tag list. The
+	// tags are selectors that may use set-based values, see Tags.Selector.
 	//
 	// For example, consider the example of catalog.mystore.com as described in NetworkEndpoints
 	// Instances(catalog.myservice.com, 80) ->
@@ -199,16 +208,6 @@ type ServiceAccounts interface {
 	GetIstioServiceAccounts(hostname string, ports []string) []string
 }
 
-// SubsetOf is true if the tag has identical values for the keys
-func (t Tags) SubsetOf(that Tags) bool {
-	for k, v := range t {
-		if that[k] != v {
-			return false
-		}
-	}
-	return true
-}
-
 // Equals returns true if the tags are identical
 func (t Tags) Equals(that Tags) bool {
 	if t == nil {
@@ -217,21 +216,15 @@ func (t Tags) Equals(that Tags) bool {
 	if that == nil {
 		return t == nil
 	}
-	return t.SubsetOf(that) && that.SubsetOf(t)
-}
-
-// HasSubsetOf returns true if the input tags are a super set of one tags in a
-// collection or if the tag collection is empty
-func (tags TagsList) HasSubsetOf(that Tags) bool {
-	if len(tags) == 0 {
-		return true
+	if len(t) != len(that) {
+		return false
 	}
-	for _, tag := range tags {
-		if tag.SubsetOf(that) {
-			return true
+	for k, v := range t {
+		if value, exists := that[k]; !exists || value != v {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
 // GetNames returns port names
@@ -375,13 +368,14 @@ func (t Tags) String() string {
 	return buffer.String()
 }
 
-// ParseTagString extracts tags from a string
+// ParseTagString extracts tags from a string. Commas within parentheses are
+// part of the set-based tag values, e.g. "version=notin(v1,v2)".
 func ParseTagString(s string) Tags {
-	pairs := strings.Split(s, ",")
+	pairs := splitTerms(s)
 	tag := make(map[string]string, len(pairs))
 
 	for _, pair := range pairs {
-		kv := strings.Split(pair, "=")
+		kv := strings.SplitN(pair, "=", 2)
 		if len(kv) > 1 {
 			tag[kv[0]] = kv[1]
 		} else {
@@ -118,15 +118,6 @@ func TestTags(t *testing.T) {
 	// equivalent to empty tag list
 	singleton := TagsList{nil}
 
-	var empty Tags
-	if !empty.SubsetOf(a) {
-		t.Errorf("nil.SubsetOf({a}) => Got false")
-	}
-
-	if a.SubsetOf(empty) {
-		t.Errorf("{a}.SubsetOf(nil) => Got true")
-	}
-
 	matching := []struct {
 		tag  Tags
 		list TagsList
@@ -140,17 +131,17 @@ func TestTags(t *testing.T) {
 		{b, a1b},
 	}
 
-	if (TagsList{a}).HasSubsetOf(b) {
-		t.Errorf("{a}.HasSubsetOf(b) => Got true")
+	if (TagsList{a}).Matches(b) {
+		t.Errorf("{a}.Matches(b) => Got true")
 	}
 
-	if a1.SubsetOf(a) {
-		t.Errorf("%v.SubsetOf(%v) => Got true", a1, a)
+	if (TagsList{a1}).Matches(a) {
+		t.Errorf("{%v}.Matches(%v) => Got true", a1, a)
 	}
 
 	for _, pair := range matching {
-		if !pair.list.HasSubsetOf(pair.tag) {
-			t.Errorf("%v.HasSubsetOf(%v) => Got false", pair.list, pair.tag)
+		if !pair.list.Matches(pair.tag) {
+			t.Errorf("%v.Matches(%v) => Got false", pair.list, pair.tag)
 		}
 	}
 }
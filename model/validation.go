@@ -229,7 +229,7 @@ func ValidateMatchCondition(mc *proxyconfig.MatchCondition) (errs error) {
 		}
 	}
 
-	if err := Tags(mc.SourceTags).Validate(); err != nil {
+	if err := Tags(mc.SourceTags).ValidateSelector(); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
@@ -338,7 +338,7 @@ func ValidateDestinationWeight(dw *proxyconfig.DestinationWeight) (errs error) {
 		}
 	}
 
-	if err := Tags(dw.Tags).Validate(); err != nil {
+	if err := Tags(dw.Tags).ValidateSelector(); err != nil {
 		errs = multierror.Append(errs, err)
 	}
 
@@ -401,7 +401,6 @@ func ValidateL4Fault(fault *proxyconfig.L4FaultInjection) (errs error) {
 		if err := ValidateTerminate(fault.GetTerminate()); err != nil {
 			errs = multierror.Append(errs, err)
 		}
-		errs = multierror.Append(errs, fmt.Errorf("Istio does not support the terminate fault yet"))
 	}
 
 	if fault.GetThrottle() != nil {
@@ -524,6 +523,12 @@ func ValidateTerminate(terminate *proxyconfig.L4FaultInjection_Terminate) (errs
 	if err := ValidateFloatPercent(terminate.Percent); err != nil {
 		errs = multierror.Append(errs, multierror.Prefix(err, "terminate percent invalid: "))
 	}
+
+	if terminate.TerminateAfterPeriod != nil {
+		if err := ValidateDuration(terminate.TerminateAfterPeriod); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("terminateAfterPeriod invalid"))
+		}
+	}
 	return
 }
 
@@ -541,17 +546,21 @@ func ValidateThrottle(throttle *proxyconfig.L4FaultInjection_Throttle) (errs err
 		errs = multierror.Append(errs, fmt.Errorf("upstreamLimitBps invalid"))
 	}
 
-	err := ValidateDuration(throttle.GetThrottleAfterPeriod())
-	if err != nil {
-		errs = multierror.Append(errs, fmt.Errorf("throttleAfterPeriod invalid"))
+	switch throttle.ThrottleAfter.(type) {
+	case *proxyconfig.L4FaultInjection_Throttle_ThrottleAfterPeriod:
+		if err := ValidateDuration(throttle.GetThrottleAfterPeriod()); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("throttleAfterPeriod invalid"))
+		}
+	case *proxyconfig.L4FaultInjection_Throttle_ThrottleAfterBytes:
+		if throttle.GetThrottleAfterBytes() < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("throttleAfterBytes invalid"))
+		}
 	}
 
-	if throttle.GetThrottleAfterBytes() < 0 {
-		errs = multierror.Append(errs, fmt.Errorf("throttleAfterBytes invalid"))
+	if throttle.GetThrottleForSeconds() != nil && throttle.GetThrottleForSeconds().Value < 0 {
+		errs = multierror.Append(errs, fmt.Errorf("throttleForSeconds invalid"))
 	}
 
-	// TODO Check DoubleValue throttle.GetThrottleForSeconds()
-
 	return
 }
 
@@ -710,7 +719,7 @@ func ValidateRouteRule(msg proto.Message) error {
 		if err := ValidateL4Fault(value.L4Fault); err != nil {
 			errs = multierror.Append(errs, err)
 		}
-		errs = multierror.Append(errs, fmt.Errorf("L4 faults are not implemented"))
+		errs = multierror.Append(errs, fmt.Errorf("L4 faults are not supported: Envoy has no TCP fault injection filter"))
 	}
 
 	return errs
@@ -757,7 +766,7 @@ func ValidateDestinationPolicy(msg proto.Message) error {
 	}
 
 	for _, policy := range value.Policy {
-		if err := Tags(policy.Tags).Validate(); err != nil {
+		if err := Tags(policy.Tags).ValidateSelector(); err != nil {
 			errs = multierror.Append(errs, err)
 		}
 
@@ -447,6 +447,24 @@ func TestValidateRouteAndIngressRule(t *testing.T) {
 			},
 		},
 			valid: false},
+		{name: "route rule unsupported l4 fault", in: &proxyconfig.RouteRule{
+			Destination: "host.default.svc.cluster.local",
+			Name:        "test",
+			L4Fault: &proxyconfig.L4FaultInjection{
+				Throttle: &proxyconfig.L4FaultInjection_Throttle{
+					Percent:            50,
+					DownstreamLimitBps: 1024,
+					UpstreamLimitBps:   2048,
+					ThrottleAfter: &proxyconfig.L4FaultInjection_Throttle_ThrottleAfterBytes{
+						ThrottleAfterBytes: 4096},
+				},
+				Terminate: &proxyconfig.L4FaultInjection_Terminate{
+					Percent:              10,
+					TerminateAfterPeriod: &duration.Duration{Seconds: 30},
+				},
+			},
+		},
+			valid: false},
 		{name: "route rule bad match source tag label", in: &proxyconfig.RouteRule{
 			Destination: "host.default.svc.cluster.local",
 			Name:        "test",
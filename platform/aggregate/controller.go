@@ -0,0 +1,153 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aggregate merges several service registries into a single view of
+// the mesh.
+package aggregate
+
+import (
+	"github.com/golang/glog"
+	multierror "github.com/hashicorp/go-multierror"
+
+	"istio.io/pilot/model"
+)
+
+// Registry is a service registry together with its change notifications
+type Registry struct {
+	// Name identifies the registry, e.g. "Kubernetes"
+	Name string
+
+	model.ServiceDiscovery
+	model.ServiceAccounts
+	model.Controller
+}
+
+// Controller aggregates the services and instances of several registries.
+// Registries are listed in the order of priority: a service declared in more
+// than one registry is served, together with its instances and service
+// accounts, by the first registry that declares it.
+type Controller struct {
+	registries []Registry
+}
+
+// NewController creates an aggregate of the registries in the order of priority
+func NewController(registries ...Registry) *Controller {
+	return &Controller{registries: registries}
+}
+
+// Services implements a service catalog operation
+func (c *Controller) Services() []*model.Service {
+	seen := make(map[string]string)
+	out := make([]*model.Service, 0)
+	for _, r := range c.registries {
+		for _, service := range r.Services() {
+			if owner, exists := seen[service.Hostname]; exists {
+				glog.V(2).Infof("Service %q in registry %s is shadowed by registry %s",
+					service.Hostname, r.Name, owner)
+				continue
+			}
+			seen[service.Hostname] = r.Name
+			out = append(out, service)
+		}
+	}
+	return out
+}
+
+// GetService implements a service catalog operation
+func (c *Controller) GetService(hostname string) (*model.Service, bool) {
+	for _, r := range c.registries {
+		if service, exists := r.GetService(hostname); exists {
+			return service, true
+		}
+	}
+	return nil, false
+}
+
+// owner returns the index of the registry serving a hostname
+func (c *Controller) owner(hostname string) (int, bool) {
+	for i, r := range c.registries {
+		if _, exists := r.GetService(hostname); exists {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Instances implements a service catalog operation
+func (c *Controller) Instances(hostname string, ports []string, tags model.TagsList) []*model.ServiceInstance {
+	if i, exists := c.owner(hostname); exists {
+		return c.registries[i].Instances(hostname, ports, tags)
+	}
+	return make([]*model.ServiceInstance, 0)
+}
+
+// HostInstances implements a service catalog operation. Instances of
+// services shadowed by a registry of higher priority are omitted.
+func (c *Controller) HostInstances(addrs map[string]bool) []*model.ServiceInstance {
+	owners := make(map[string]int)
+	out := make([]*model.ServiceInstance, 0)
+	for i, r := range c.registries {
+		for _, instance := range r.HostInstances(addrs) {
+			hostname := instance.Service.Hostname
+			owner, cached := owners[hostname]
+			if !cached {
+				owner, _ = c.owner(hostname)
+				owners[hostname] = owner
+			}
+			if owner == i {
+				out = append(out, instance)
+			}
+		}
+	}
+	return out
+}
+
+// GetIstioServiceAccounts implements service account lookup
+func (c *Controller) GetIstioServiceAccounts(hostname string, ports []string) []string {
+	if i, exists := c.owner(hostname); exists {
+		return c.registries[i].GetIstioServiceAccounts(hostname, ports)
+	}
+	return make([]string, 0)
+}
+
+// AppendServiceHandler registers the handler with every registry
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	var errs error
+	for _, r := range c.registries {
+		if err := r.AppendServiceHandler(f); err != nil {
+			errs = multierror.Append(errs, multierror.Prefix(err, r.Name+":"))
+		}
+	}
+	return errs
+}
+
+// AppendInstanceHandler registers the handler with every registry
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	var errs error
+	for _, r := range c.registries {
+		if err := r.AppendInstanceHandler(f); err != nil {
+			errs = multierror.Append(errs, multierror.Prefix(err, r.Name+":"))
+		}
+	}
+	return errs
+}
+
+// Run runs all registries until a signal is received
+func (c *Controller) Run(stop <-chan struct{}) {
+	for _, r := range c.registries {
+		go r.Run(stop)
+	}
+	<-stop
+	glog.V(2).Info("Registry aggregator terminated")
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregate
+
+import (
+	"errors"
+	"testing"
+
+	"istio.io/pilot/model"
+	"istio.io/pilot/test/mock"
+)
+
+// fakeController records the registered handlers
+type fakeController struct {
+	services  []func(*model.Service, model.Event)
+	instances []func(*model.ServiceInstance, model.Event)
+	err       error
+	runs      chan bool
+}
+
+func (c *fakeController) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.services = append(c.services, f)
+	return c.err
+}
+
+func (c *fakeController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.instances = append(c.instances, f)
+	return c.err
+}
+
+func (c *fakeController) Run(stop <-chan struct{}) {
+	c.runs <- true
+	<-stop
+}
+
+func makeRegistry(name string, discovery *mock.ServiceDiscovery) (Registry, *fakeController) {
+	ctl := &fakeController{runs: make(chan bool, 1)}
+	return Registry{
+		Name:             name,
+		ServiceDiscovery: discovery,
+		ServiceAccounts:  discovery,
+		Controller:       ctl,
+	}, ctl
+}
+
+func TestAggregateDiscovery(t *testing.T) {
+	// hello is declared in both registries with different addresses
+	shadowed := mock.MakeService(mock.HelloService.Hostname, "10.9.0.0")
+	primary, _ := makeRegistry("primary", mock.NewDiscovery(map[string]*model.Service{
+		mock.HelloService.Hostname: mock.HelloService,
+	}, 2))
+	secondary, _ := makeRegistry("secondary", mock.NewDiscovery(map[string]*model.Service{
+		shadowed.Hostname:          shadowed,
+		mock.WorldService.Hostname: mock.WorldService,
+	}, 1))
+	ctl := NewController(primary, secondary)
+
+	if services := ctl.Services(); len(services) != 2 {
+		t.Errorf("got services %v, want hello and world", services)
+	}
+	if service, ok := ctl.GetService(mock.HelloService.Hostname); !ok || service.Address != mock.HelloService.Address {
+		t.Errorf("got service %v, want the primary hello service", service)
+	}
+	if _, ok := ctl.GetService("unknown.default.svc.cluster.local"); ok {
+		t.Error("got unknown service")
+	}
+
+	instances := ctl.Instances(mock.HelloService.Hostname, []string{"http"}, nil)
+	if len(instances) != 2 || instances[0].Service != mock.HelloService {
+		t.Errorf("got instances %v, want two primary instances", instances)
+	}
+	if got := ctl.Instances(mock.WorldService.Hostname, []string{"http"}, nil); len(got) != 1 {
+		t.Errorf("got instances %v, want one secondary instance", got)
+	}
+
+	addrs := map[string]bool{
+		mock.HostInstanceV0:               true,
+		mock.MakeIP(shadowed, 0):          true,
+		mock.MakeIP(mock.WorldService, 0): true,
+	}
+	for _, instance := range ctl.HostInstances(addrs) {
+		if instance.Service == shadowed {
+			t.Errorf("got instance %v of a shadowed service", instance)
+		}
+	}
+	if got := ctl.HostInstances(addrs); len(got) != 2*len(mock.HelloService.Ports) {
+		t.Errorf("got %d host instances, want %d", len(got), 2*len(mock.HelloService.Ports))
+	}
+
+	if got := ctl.GetIstioServiceAccounts(mock.WorldService.Hostname, []string{"http"}); len(got) != 2 {
+		t.Errorf("got service accounts %v, want the secondary accounts", got)
+	}
+}
+
+func TestAggregateController(t *testing.T) {
+	first, firstCtl := makeRegistry("first", mock.Discovery)
+	second, secondCtl := makeRegistry("second", mock.Discovery)
+	secondCtl.err = errors.New("unsupported")
+	ctl := NewController(first, second)
+
+	if err := ctl.AppendServiceHandler(func(*model.Service, model.Event) {}); err == nil {
+		t.Error("expected error from the second registry")
+	}
+	if err := ctl.AppendInstanceHandler(func(*model.ServiceInstance, model.Event) {}); err == nil {
+		t.Error("expected error from the second registry")
+	}
+	if len(firstCtl.services) != 1 || len(firstCtl.instances) != 1 ||
+		len(secondCtl.services) != 1 || len(secondCtl.instances) != 1 {
+		t.Error("expected handlers to be registered with all registries")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+	<-firstCtl.runs
+	<-secondCtl.runs
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexHeader carries the index of the catalog state in Consul responses
+const indexHeader = "X-Consul-Index"
+
+// catalogService is a service instance registered in the Consul catalog
+type catalogService struct {
+	Node           string   `json:"Node"`
+	Address        string   `json:"Address"`
+	ServiceID      string   `json:"ServiceID"`
+	ServiceName    string   `json:"ServiceName"`
+	ServiceAddress string   `json:"ServiceAddress"`
+	ServiceTags    []string `json:"ServiceTags"`
+	ServicePort    int      `json:"ServicePort"`
+}
+
+// client reads the Consul catalog over the HTTP API
+type client struct {
+	address    string
+	datacenter string
+	http       *http.Client
+}
+
+func newClient(address, datacenter string) *client {
+	return &client{
+		address:    strings.TrimSuffix(address, "/"),
+		datacenter: datacenter,
+		http:       &http.Client{},
+	}
+}
+
+// services lists the names and the tags of the services in the catalog. If the
+// index is set, the query blocks until the catalog index exceeds it or the wait
+// time elapses. The index of the catalog state is returned.
+func (c *client) services(ctx context.Context, index uint64, wait time.Duration) (map[string][]string, uint64, error) {
+	query := url.Values{}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%dms", wait/time.Millisecond))
+	}
+	out := make(map[string][]string)
+	next, err := c.get(ctx, "/v1/catalog/services", query, &out)
+	if err == nil && next == 0 {
+		err = fmt.Errorf("missing %s header", indexHeader)
+	}
+	return out, next, err
+}
+
+// service lists the instances of a service in the catalog
+func (c *client) service(ctx context.Context, name string) ([]*catalogService, error) {
+	out := make([]*catalogService, 0)
+	_, err := c.get(ctx, "/v1/catalog/service/"+url.PathEscape(name), url.Values{}, &out)
+	return out, err
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) (uint64, error) {
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	uri := c.address + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %q for %s", resp.Status, path)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	var index uint64
+	if header := resp.Header.Get(indexHeader); header != "" {
+		if index, err = strconv.ParseUint(header, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid %s header %q: %v", indexHeader, header, err)
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consul provides a service registry backed by the Consul catalog.
+//
+// A Consul service "reviews" is exposed as the hostname
+// "reviews.service.consul". The instance tags of the form "key|value" are
+// converted to the instance tags, except for the reserved keys: "protocol"
+// names the instance port, which determines the port protocol as for
+// Kubernetes service ports (e.g. "protocol|http"), and "service_account"
+// declares the service account of the instance.
+package consul
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"istio.io/pilot/model"
+)
+
+// ControllerOptions stores the configurable attributes of a Controller.
+type ControllerOptions struct {
+	// Address of the Consul HTTP API, e.g. "http://127.0.0.1:8500"
+	Address string
+
+	// Datacenter to query, defaults to the datacenter of the agent
+	Datacenter string
+
+	// DomainSuffix of the service hostnames, defaults to "consul"
+	DomainSuffix string
+
+	// WaitTime is the maximum duration of a blocking catalog query, defaults
+	// to 5 minutes
+	WaitTime time.Duration
+
+	// RetryDelay is the delay before retrying a failed catalog query,
+	// defaults to 1 second
+	RetryDelay time.Duration
+}
+
+// Default controller options
+const (
+	defaultDomainSuffix = "consul"
+	defaultWaitTime     = 5 * time.Minute
+	defaultRetryDelay   = time.Second
+)
+
+// endpoint is a service instance together with its service account
+type endpoint struct {
+	instance       *model.ServiceInstance
+	serviceAccount string
+}
+
+// Controller is a service registry that mirrors the Consul catalog. The
+// catalog is watched with blocking queries, and the handlers are notified of
+// the services and instances that change.
+type Controller struct {
+	client  *client
+	options ControllerOptions
+
+	mu               sync.RWMutex
+	services         map[string]*model.Service
+	endpoints        map[string][]endpoint
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController creates a Consul catalog controller. The catalog is empty
+// until the controller runs.
+func NewController(options ControllerOptions) *Controller {
+	if options.DomainSuffix == "" {
+		options.DomainSuffix = defaultDomainSuffix
+	}
+	if options.WaitTime == 0 {
+		options.WaitTime = defaultWaitTime
+	}
+	if options.RetryDelay == 0 {
+		options.RetryDelay = defaultRetryDelay
+	}
+	return &Controller{
+		client:    newClient(options.Address, options.Datacenter),
+		options:   options,
+		services:  make(map[string]*model.Service),
+		endpoints: make(map[string][]endpoint),
+	}
+}
+
+// Services implements a service catalog operation
+func (c *Controller) Services() []*model.Service {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*model.Service, 0, len(c.services))
+	for _, service := range c.services {
+		out = append(out, service)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	return out
+}
+
+// GetService implements a service catalog operation
+func (c *Controller) GetService(hostname string) (*model.Service, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	service, exists := c.services[hostname]
+	return service, exists
+}
+
+// Instances implements a service catalog operation
+func (c *Controller) Instances(hostname string, ports []string, tagsList model.TagsList) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0)
+	for _, ep := range c.matchEndpoints(hostname, ports, tagsList) {
+		out = append(out, ep.instance)
+	}
+	return out
+}
+
+func (c *Controller) matchEndpoints(hostname string, ports []string, tagsList model.TagsList) []endpoint {
+	names := make(map[string]bool)
+	for _, name := range ports {
+		names[name] = true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]endpoint, 0)
+	for _, ep := range c.endpoints[hostname] {
+		if names[ep.instance.Endpoint.ServicePort.Name] && tagsList.Matches(ep.instance.Tags) {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+// HostInstances implements a service catalog operation
+func (c *Controller) HostInstances(addrs map[string]bool) []*model.ServiceInstance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*model.ServiceInstance, 0)
+	for _, endpoints := range c.endpoints {
+		for _, ep := range endpoints {
+			if addrs[ep.instance.Endpoint.Address] {
+				out = append(out, ep.instance)
+			}
+		}
+	}
+	return out
+}
+
+// GetIstioServiceAccounts returns the service accounts declared by the
+// instances of a service
+func (c *Controller) GetIstioServiceAccounts(hostname string, ports []string) []string {
+	set := make(map[string]bool)
+	for _, ep := range c.matchEndpoints(hostname, ports, nil) {
+		if ep.serviceAccount != "" {
+			set[ep.serviceAccount] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for account := range set {
+		out = append(out, account)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.mu.Lock()
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	c.mu.Unlock()
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation. Instance
+// handlers receive an incomplete instance that holds only the service, since
+// the instances of a service are reloaded together.
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.mu.Lock()
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	c.mu.Unlock()
+	return nil
+}
+
+// Run watches the catalog until a signal is received
+func (c *Controller) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	var index uint64
+	for {
+		names, next, err := c.client.services(ctx, index, c.options.WaitTime)
+		if err == nil && next != index {
+			err = c.reload(ctx, names)
+		}
+		if ctx.Err() != nil {
+			glog.V(2).Info("Consul controller terminated")
+			return
+		}
+		if err != nil {
+			glog.Warningf("Failed to read the Consul catalog: %v", err)
+			select {
+			case <-time.After(c.options.RetryDelay):
+			case <-stop:
+			}
+			continue
+		}
+		// the index may go backwards, e.g. after a restore of the catalog
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// reload reads the instances of all services and notifies the handlers of the
+// differences with the last state
+func (c *Controller) reload(ctx context.Context, names map[string][]string) error {
+	services := make(map[string]*model.Service, len(names))
+	endpoints := make(map[string][]endpoint, len(names))
+	for name := range names {
+		instances, err := c.client.service(ctx, name)
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			continue
+		}
+		service := convertService(name, instances, c.options.DomainSuffix)
+		services[service.Hostname] = service
+		for _, instance := range instances {
+			converted, account := convertInstance(service, instance)
+			endpoints[service.Hostname] = append(endpoints[service.Hostname], endpoint{
+				instance:       converted,
+				serviceAccount: account,
+			})
+		}
+		sort.Slice(endpoints[service.Hostname], func(i, j int) bool {
+			a, b := endpoints[service.Hostname][i].instance.Endpoint, endpoints[service.Hostname][j].instance.Endpoint
+			return a.Address < b.Address || (a.Address == b.Address && a.Port < b.Port)
+		})
+	}
+
+	c.mu.Lock()
+	prevServices, prevEndpoints := c.services, c.endpoints
+	c.services, c.endpoints = services, endpoints
+	serviceHandlers, instanceHandlers := c.serviceHandlers, c.instanceHandlers
+	c.mu.Unlock()
+
+	hostnames := make(map[string]bool)
+	for hostname := range prevServices {
+		hostnames[hostname] = true
+	}
+	for hostname := range services {
+		hostnames[hostname] = true
+	}
+	sorted := make([]string, 0, len(hostnames))
+	for hostname := range hostnames {
+		sorted = append(sorted, hostname)
+	}
+	sort.Strings(sorted)
+
+	for _, hostname := range sorted {
+		before, existed := prevServices[hostname]
+		after, exists := services[hostname]
+		var event model.Event
+		switch {
+		case !existed:
+			event = model.EventAdd
+		case !exists:
+			event, after = model.EventDelete, before
+		case !reflect.DeepEqual(before, after):
+			event = model.EventUpdate
+		case reflect.DeepEqual(prevEndpoints[hostname], endpoints[hostname]):
+			continue
+		default:
+			// only the instances changed
+			for _, f := range instanceHandlers {
+				f(&model.ServiceInstance{Service: after}, model.EventUpdate)
+			}
+			continue
+		}
+		for _, f := range serviceHandlers {
+			f(after, event)
+		}
+		for _, f := range instanceHandlers {
+			f(&model.ServiceInstance{Service: after}, event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/pilot/model"
+)
+
+// fakeCatalog stands in for the Consul catalog HTTP API
+type fakeCatalog struct {
+	mu       sync.Mutex
+	index    uint64
+	services map[string][]*catalogService
+	changed  chan struct{}
+}
+
+func newFakeCatalog() *fakeCatalog {
+	return &fakeCatalog{
+		index:    1,
+		services: make(map[string][]*catalogService),
+		changed:  make(chan struct{}),
+	}
+}
+
+// register replaces the instances of a service and wakes up blocking queries
+func (f *fakeCatalog) register(name string, instances ...*catalogService) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(instances) == 0 {
+		delete(f.services, name)
+	} else {
+		f.services[name] = instances
+	}
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index == f.index {
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+	}
+	defer f.mu.Unlock()
+
+	w.Header().Set(indexHeader, strconv.FormatUint(f.index, 10))
+	var out interface{}
+	switch {
+	case r.URL.Path == "/v1/catalog/services":
+		names := make(map[string][]string)
+		for name, instances := range f.services {
+			names[name] = instances[0].ServiceTags
+		}
+		out = names
+	case strings.HasPrefix(r.URL.Path, "/v1/catalog/service/"):
+		instances, exists := f.services[strings.TrimPrefix(r.URL.Path, "/v1/catalog/service/")]
+		if !exists {
+			instances = make([]*catalogService, 0)
+		}
+		out = instances
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+type event struct {
+	hostname string
+	event    model.Event
+	instance bool
+}
+
+func expectEvents(t *testing.T, events <-chan event, want ...event) {
+	for _, ev := range want {
+		select {
+		case got := <-events:
+			if got != ev {
+				t.Errorf("got event %v, want %v", got, ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %v", ev)
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	catalog := newFakeCatalog()
+	catalog.register("reviews", &catalogService{
+		Node:        "node1",
+		Address:     "10.0.0.1",
+		ServiceName: "reviews",
+		ServiceTags: []string{"version|v1", "protocol|http", "service_account|spiffe://cluster.local/ns/default/sa/reviews"},
+		ServicePort: 9080,
+	})
+	server := httptest.NewServer(catalog)
+	defer server.Close()
+
+	ctl := NewController(ControllerOptions{Address: server.URL, WaitTime: time.Second})
+	events := make(chan event, 10)
+	_ = ctl.AppendServiceHandler(func(s *model.Service, ev model.Event) {
+		events <- event{hostname: s.Hostname, event: ev}
+	})
+	_ = ctl.AppendInstanceHandler(func(i *model.ServiceInstance, ev model.Event) {
+		events <- event{hostname: i.Service.Hostname, event: ev, instance: true}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	const hostname = "reviews.service.consul"
+	expectEvents(t, events, event{hostname, model.EventAdd, false}, event{hostname, model.EventAdd, true})
+	if _, ok := ctl.GetService(hostname); !ok {
+		t.Fatalf("missing service %q in %v", hostname, ctl.Services())
+	}
+	if got := ctl.Instances(hostname, []string{"http"}, model.TagsList{{"version": "v1"}}); len(got) != 1 {
+		t.Errorf("got instances %v, want one", got)
+	}
+	if got := ctl.HostInstances(map[string]bool{"10.0.0.1": true}); len(got) != 1 {
+		t.Errorf("got host instances %v, want one", got)
+	}
+	if got := ctl.GetIstioServiceAccounts(hostname, []string{"http"}); len(got) != 1 {
+		t.Errorf("got service accounts %v, want one", got)
+	}
+
+	// a new instance on the same port changes only the instances
+	catalog.register("reviews", &catalogService{
+		Node:        "node1",
+		Address:     "10.0.0.1",
+		ServiceName: "reviews",
+		ServiceTags: []string{"version|v1", "protocol|http"},
+		ServicePort: 9080,
+	}, &catalogService{
+		Node:        "node2",
+		Address:     "10.0.0.2",
+		ServiceName: "reviews",
+		ServiceTags: []string{"version|v2", "protocol|http"},
+		ServicePort: 9080,
+	})
+	expectEvents(t, events, event{hostname, model.EventUpdate, true})
+	if got := ctl.Instances(hostname, []string{"http"}, nil); len(got) != 2 {
+		t.Errorf("got instances %v, want two", got)
+	}
+
+	// a deregistered service deletes the service and its instances
+	catalog.register("reviews")
+	expectEvents(t, events, event{hostname, model.EventDelete, false}, event{hostname, model.EventDelete, true})
+	if got := ctl.Services(); len(got) != 0 {
+		t.Errorf("got services %v, want none", got)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"istio.io/pilot/model"
+)
+
+const (
+	// tagSeparator splits a Consul service tag into the key and the value
+	tagSeparator = "|"
+
+	// protocolTagName is the reserved tag key for the port name, which
+	// determines the protocol as for Kubernetes service ports
+	protocolTagName = "protocol"
+
+	// serviceAccountTagName is the reserved tag key for the service account
+	serviceAccountTagName = "service_account"
+
+	// defaultPortName is the prefix of the port name for the instances without
+	// a protocol tag
+	defaultPortName = "tcp"
+)
+
+// serviceHostname produces the FQDN of a Consul service
+func serviceHostname(name, domainSuffix string) string {
+	return fmt.Sprintf("%s.service.%s", name, domainSuffix)
+}
+
+// parseTags splits the Consul service tags of the form "key|value" into the
+// instance tags and the reserved tags. Tags without a separator are ignored.
+func parseTags(tags []string) (model.Tags, map[string]string) {
+	out := make(model.Tags)
+	reserved := make(map[string]string)
+	for _, tag := range tags {
+		parts := strings.SplitN(tag, tagSeparator, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case protocolTagName, serviceAccountTagName:
+			reserved[parts[0]] = parts[1]
+		default:
+			out[parts[0]] = parts[1]
+		}
+	}
+	return out, reserved
+}
+
+// convertPort names an untagged port by the default protocol and the port number
+func convertPort(port int, name string) *model.Port {
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", defaultPortName, port)
+	}
+	return &model.Port{
+		Name:     name,
+		Port:     port,
+		Protocol: convertProtocol(name),
+	}
+}
+
+// convertProtocol derives the protocol from the port name prefix
+func convertProtocol(name string) model.Protocol {
+	prefix := name
+	if i := strings.Index(name, "-"); i >= 0 {
+		prefix = name[:i]
+	}
+	switch strings.ToLower(prefix) {
+	case "grpc":
+		return model.ProtocolGRPC
+	case "http":
+		return model.ProtocolHTTP
+	case "http2":
+		return model.ProtocolHTTP2
+	case "https":
+		return model.ProtocolHTTPS
+	case "udp":
+		return model.ProtocolUDP
+	}
+	return model.ProtocolTCP
+}
+
+// convertService builds a service from its registered instances. Consul does
+// not allocate service addresses, and the service ports are the union of the
+// instance ports. Ports tagged with the same name are made unique by the port
+// number.
+func convertService(name string, endpoints []*catalogService, domainSuffix string) *model.Service {
+	ports := make(map[int]*model.Port)
+	for _, endpoint := range endpoints {
+		_, reserved := parseTags(endpoint.ServiceTags)
+		if _, exists := ports[endpoint.ServicePort]; !exists {
+			ports[endpoint.ServicePort] = convertPort(endpoint.ServicePort, reserved[protocolTagName])
+		}
+	}
+	out := &model.Service{
+		Hostname: serviceHostname(name, domainSuffix),
+		Ports:    make(model.PortList, 0, len(ports)),
+	}
+	for _, port := range ports {
+		out.Ports = append(out.Ports, port)
+	}
+	sort.Slice(out.Ports, func(i, j int) bool { return out.Ports[i].Port < out.Ports[j].Port })
+	names := make(map[string]bool)
+	for _, port := range out.Ports {
+		if names[port.Name] {
+			port.Name = fmt.Sprintf("%s-%d", port.Name, port.Port)
+		}
+		names[port.Name] = true
+	}
+	return out
+}
+
+// convertInstance builds a service instance from a registered instance. The
+// instance address defaults to the address of the node.
+func convertInstance(service *model.Service, endpoint *catalogService) (*model.ServiceInstance, string) {
+	tags, reserved := parseTags(endpoint.ServiceTags)
+	addr := endpoint.ServiceAddress
+	if addr == "" {
+		addr = endpoint.Address
+	}
+	port, _ := service.Ports.GetByPort(endpoint.ServicePort)
+	return &model.ServiceInstance{
+		Endpoint: model.NetworkEndpoint{
+			Address:     addr,
+			Port:        endpoint.ServicePort,
+			ServicePort: port,
+		},
+		Service: service,
+		Tags:    tags,
+	}, reserved[serviceAccountTagName]
+}
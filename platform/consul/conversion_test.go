@@ -0,0 +1,110 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/pilot/model"
+)
+
+func TestConvertProtocol(t *testing.T) {
+	cases := map[string]model.Protocol{
+		"":          model.ProtocolTCP,
+		"tcp":       model.ProtocolTCP,
+		"http":      model.ProtocolHTTP,
+		"HTTP":      model.ProtocolHTTP,
+		"http-web":  model.ProtocolHTTP,
+		"http2":     model.ProtocolHTTP2,
+		"grpc":      model.ProtocolGRPC,
+		"https":     model.ProtocolHTTPS,
+		"udp":       model.ProtocolUDP,
+		"something": model.ProtocolTCP,
+	}
+	for name, want := range cases {
+		if got := convertProtocol(name); got != want {
+			t.Errorf("convertProtocol(%q) => %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, reserved := parseTags([]string{
+		"version|v1",
+		"zone|us-east",
+		"protocol|http",
+		"service_account|spiffe://cluster.local/ns/default/sa/reviews",
+		"primary",
+	})
+	if want := (model.Tags{"version": "v1", "zone": "us-east"}); !reflect.DeepEqual(tags, want) {
+		t.Errorf("got tags %v, want %v", tags, want)
+	}
+	want := map[string]string{
+		protocolTagName:       "http",
+		serviceAccountTagName: "spiffe://cluster.local/ns/default/sa/reviews",
+	}
+	if !reflect.DeepEqual(reserved, want) {
+		t.Errorf("got reserved tags %v, want %v", reserved, want)
+	}
+}
+
+func TestServiceConversion(t *testing.T) {
+	endpoints := []*catalogService{{
+		Node:        "node1",
+		Address:     "10.0.0.1",
+		ServiceName: "reviews",
+		ServiceTags: []string{"version|v1", "protocol|http"},
+		ServicePort: 9080,
+	}, {
+		Node:           "node2",
+		Address:        "10.0.0.2",
+		ServiceName:    "reviews",
+		ServiceAddress: "172.16.0.2",
+		ServiceTags:    []string{"version|v2"},
+		ServicePort:    9090,
+	}, {
+		Node:        "node3",
+		Address:     "10.0.0.3",
+		ServiceName: "reviews",
+		ServiceTags: []string{"version|v3", "protocol|http"},
+		ServicePort: 9081,
+	}}
+
+	service := convertService("reviews", endpoints, "consul")
+	want := &model.Service{
+		Hostname: "reviews.service.consul",
+		Ports: model.PortList{
+			{Name: "http", Port: 9080, Protocol: model.ProtocolHTTP},
+			{Name: "http-9081", Port: 9081, Protocol: model.ProtocolHTTP},
+			{Name: "tcp-9090", Port: 9090, Protocol: model.ProtocolTCP},
+		},
+	}
+	if !reflect.DeepEqual(service, want) {
+		t.Errorf("got service %#v, want %#v", service, want)
+	}
+	if err := service.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	instance, _ := convertInstance(service, endpoints[1])
+	if instance.Endpoint.Address != "172.16.0.2" || instance.Endpoint.ServicePort.Name != "tcp-9090" ||
+		instance.Tags["version"] != "v2" {
+		t.Errorf("got instance %#v", instance)
+	}
+	if err := instance.Validate(); err != nil {
+		t.Error(err)
+	}
+}
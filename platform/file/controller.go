@@ -0,0 +1,435 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package file provides a service registry declared in a YAML or JSON file,
+// for example
+//
+//	services:
+//	- hostname: reviews.default.svc.cluster.local
+//	  address: 10.0.0.10
+//	  ports:
+//	  - name: http
+//	    port: 9080
+//	    protocol: HTTP
+//	  instances:
+//	  - address: 10.1.1.1
+//	    ports:
+//	      http: 19080
+//	    tags:
+//	      version: v1
+//	    service_account: spiffe://cluster.local/ns/default/sa/reviews
+//
+// Every instance listens on all ports of its service. The instance ports map
+// the service port names to the endpoint ports, which default to the service
+// port numbers. The protocol of a service port defaults to TCP.
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/howeyc/fsnotify"
+
+	"istio.io/pilot/model"
+)
+
+// eventDelay is the quiet period after a change to the file before it is
+// reloaded, so that a partially written file is not loaded
+const eventDelay = 100 * time.Millisecond
+
+// registryFile is the declared service topology
+type registryFile struct {
+	Services []*serviceEntry `json:"services"`
+}
+
+type serviceEntry struct {
+	model.Service
+	Instances []*instanceEntry `json:"instances,omitempty"`
+}
+
+type instanceEntry struct {
+	Address        string         `json:"address"`
+	Ports          map[string]int `json:"ports,omitempty"`
+	Tags           model.Tags     `json:"tags,omitempty"`
+	ServiceAccount string         `json:"service_account,omitempty"`
+}
+
+// endpoint is a service instance together with its service account
+type endpoint struct {
+	instance       *model.ServiceInstance
+	serviceAccount string
+}
+
+// registry is the parsed content of the file
+type registry struct {
+	services  map[string]*model.Service
+	endpoints map[string][]endpoint
+}
+
+// Controller is a service registry that loads the services and their
+// instances from a file and notifies the handlers of the differences
+// whenever the file changes. A file that fails to parse or validate is
+// reported, and the last valid content is retained.
+type Controller struct {
+	path string
+
+	mu               sync.RWMutex
+	registry         *registry
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController creates a service registry from a file
+func NewController(path string) (*Controller, error) {
+	reg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Controller{
+		path:     path,
+		registry: reg,
+	}, nil
+}
+
+// load reads and validates the registry file
+func load(path string) (*registry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file registryFile
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var errs error
+	out := &registry{
+		services:  make(map[string]*model.Service),
+		endpoints: make(map[string][]endpoint),
+	}
+	for _, entry := range file.Services {
+		service := entry.Service
+		for _, port := range service.Ports {
+			if port.Protocol == "" {
+				port.Protocol = model.ProtocolTCP
+			}
+		}
+		if err = service.Validate(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid service %q: %v", service.Hostname, err))
+			continue
+		}
+		if _, exists := out.services[service.Hostname]; exists {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate service %q", service.Hostname))
+			continue
+		}
+		if service.External() && len(entry.Instances) > 0 {
+			errs = multierror.Append(errs, fmt.Errorf("external service %q must not declare instances",
+				service.Hostname))
+			continue
+		}
+		out.services[service.Hostname] = &service
+
+		for _, inst := range entry.Instances {
+			if err = model.ValidateIPv4Address(inst.Address); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid instance of %q: %v", service.Hostname, err))
+				continue
+			}
+			for name := range inst.Ports {
+				if _, exists := service.Ports.Get(name); !exists {
+					errs = multierror.Append(errs, fmt.Errorf("instance %s of %q declares unknown port %q",
+						inst.Address, service.Hostname, name))
+				}
+			}
+			for _, port := range service.Ports {
+				number, exists := inst.Ports[port.Name]
+				if !exists {
+					number = port.Port
+				}
+				instance := &model.ServiceInstance{
+					Endpoint: model.NetworkEndpoint{
+						Address:     inst.Address,
+						Port:        number,
+						ServicePort: port,
+					},
+					Service: &service,
+					Tags:    inst.Tags,
+				}
+				if err = instance.Validate(); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("invalid instance %s of %q: %v",
+						inst.Address, service.Hostname, err))
+					continue
+				}
+				out.endpoints[service.Hostname] = append(out.endpoints[service.Hostname], endpoint{
+					instance:       instance,
+					serviceAccount: inst.ServiceAccount,
+				})
+			}
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// Services implements a service catalog operation
+func (c *Controller) Services() []*model.Service {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*model.Service, 0, len(c.registry.services))
+	for _, hostname := range sortedHostnames(c.registry.services) {
+		out = append(out, c.registry.services[hostname])
+	}
+	return out
+}
+
+// GetService implements a service catalog operation
+func (c *Controller) GetService(hostname string) (*model.Service, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	service, exists := c.registry.services[hostname]
+	return service, exists
+}
+
+// Instances implements a service catalog operation
+func (c *Controller) Instances(hostname string, ports []string, tagsList model.TagsList) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0)
+	for _, ep := range c.endpoints(hostname, ports, tagsList) {
+		out = append(out, ep.instance)
+	}
+	return out
+}
+
+func (c *Controller) endpoints(hostname string, ports []string, tagsList model.TagsList) []endpoint {
+	names := make(map[string]bool)
+	for _, name := range ports {
+		names[name] = true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]endpoint, 0)
+	for _, ep := range c.registry.endpoints[hostname] {
+		if names[ep.instance.Endpoint.ServicePort.Name] && tagsList.Matches(ep.instance.Tags) {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+// HostInstances implements a service catalog operation
+func (c *Controller) HostInstances(addrs map[string]bool) []*model.ServiceInstance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]*model.ServiceInstance, 0)
+	for _, hostname := range sortedHostnames(c.registry.services) {
+		for _, ep := range c.registry.endpoints[hostname] {
+			if addrs[ep.instance.Endpoint.Address] {
+				out = append(out, ep.instance)
+			}
+		}
+	}
+	return out
+}
+
+// GetIstioServiceAccounts returns the service accounts declared by the
+// instances of a service
+func (c *Controller) GetIstioServiceAccounts(hostname string, ports []string) []string {
+	set := make(map[string]bool)
+	for _, ep := range c.endpoints(hostname, ports, nil) {
+		if ep.serviceAccount != "" {
+			set[ep.serviceAccount] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for account := range set {
+		out = append(out, account)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.mu.Lock()
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	c.mu.Unlock()
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.mu.Lock()
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	c.mu.Unlock()
+	return nil
+}
+
+// Run watches the file until a signal is received. The directory of the
+// file is watched so that files replaced by a rename are detected.
+func (c *Controller) Run(stop <-chan struct{}) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		glog.Warningf("failed to create a watcher for %s: %v", c.path, err)
+		<-stop
+		return
+	}
+	defer func() {
+		if err := fw.Close(); err != nil {
+			glog.Warningf("closing watcher encounters an error %v", err)
+		}
+	}()
+
+	if err = fw.Watch(filepath.Dir(c.path)); err != nil {
+		glog.Warningf("watching %s encounters an error %v", c.path, err)
+		<-stop
+		return
+	}
+	c.reload()
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case ev := <-fw.Event:
+			if filepath.Clean(ev.Name) == filepath.Clean(c.path) {
+				reload = time.After(eventDelay)
+			}
+
+		case <-reload:
+			reload = nil
+			c.reload()
+
+		case err := <-fw.Error:
+			glog.Warningf("service registry watcher error: %v", err)
+
+		case <-stop:
+			glog.V(2).Info("Service registry watcher is terminated")
+			return
+		}
+	}
+}
+
+// reload replaces the registry with the content of the file and notifies the
+// handlers of the differences
+func (c *Controller) reload() {
+	reg, err := load(c.path)
+	if err != nil {
+		glog.Warningf("Failed to load service registry %s, retaining its last valid content: %v", c.path, err)
+		return
+	}
+
+	c.mu.Lock()
+	prev := c.registry
+	c.registry = reg
+	serviceHandlers := c.serviceHandlers
+	instanceHandlers := c.instanceHandlers
+	c.mu.Unlock()
+
+	services, instances := diff(prev, reg)
+	for _, ev := range services {
+		for _, f := range serviceHandlers {
+			f(ev.service, ev.event)
+		}
+	}
+	for _, ev := range instances {
+		for _, f := range instanceHandlers {
+			f(ev.instance, ev.event)
+		}
+	}
+}
+
+type serviceEvent struct {
+	service *model.Service
+	event   model.Event
+}
+
+type instanceEvent struct {
+	instance *model.ServiceInstance
+	event    model.Event
+}
+
+// diff computes the service and instance events between two registries.
+// Instances are identified by the service hostname, the address, and the
+// service port name.
+func diff(prev, next *registry) ([]serviceEvent, []instanceEvent) {
+	services := make([]serviceEvent, 0)
+	hostnames := make(map[string]*model.Service)
+	for hostname, service := range prev.services {
+		hostnames[hostname] = service
+	}
+	for hostname, service := range next.services {
+		hostnames[hostname] = service
+	}
+	for _, hostname := range sortedHostnames(hostnames) {
+		before, existed := prev.services[hostname]
+		after, exists := next.services[hostname]
+		switch {
+		case !existed:
+			services = append(services, serviceEvent{after, model.EventAdd})
+		case !exists:
+			services = append(services, serviceEvent{before, model.EventDelete})
+		case !reflect.DeepEqual(before, after):
+			services = append(services, serviceEvent{after, model.EventUpdate})
+		}
+	}
+
+	prevInstances, prevKeys := indexInstances(prev)
+	nextInstances, nextKeys := indexInstances(next)
+	instances := make([]instanceEvent, 0)
+	for _, key := range prevKeys {
+		if _, exists := nextInstances[key]; !exists {
+			instances = append(instances, instanceEvent{prevInstances[key], model.EventDelete})
+		}
+	}
+	for _, key := range nextKeys {
+		before, existed := prevInstances[key]
+		after := nextInstances[key]
+		switch {
+		case !existed:
+			instances = append(instances, instanceEvent{after, model.EventAdd})
+		case !reflect.DeepEqual(before, after):
+			instances = append(instances, instanceEvent{after, model.EventUpdate})
+		}
+	}
+	return services, instances
+}
+
+func indexInstances(reg *registry) (map[string]*model.ServiceInstance, []string) {
+	out := make(map[string]*model.ServiceInstance)
+	keys := make([]string, 0)
+	for _, hostname := range sortedHostnames(reg.services) {
+		for _, ep := range reg.endpoints[hostname] {
+			key := fmt.Sprintf("%s|%s|%s", hostname, ep.instance.Endpoint.Address, ep.instance.Endpoint.ServicePort.Name)
+			out[key] = ep.instance
+			keys = append(keys, key)
+		}
+	}
+	return out, keys
+}
+
+func sortedHostnames(services map[string]*model.Service) []string {
+	out := make([]string, 0, len(services))
+	for hostname := range services {
+		out = append(out, hostname)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/pilot/model"
+)
+
+const (
+	registryYAML = `
+services:
+- hostname: reviews.default.svc.cluster.local
+  address: 10.0.0.10
+  ports:
+  - name: http
+    port: 9080
+    protocol: HTTP
+  - name: tcp
+    port: 9090
+  instances:
+  - address: 10.1.1.1
+    ports:
+      http: 19080
+    tags:
+      version: v1
+    service_account: spiffe://cluster.local/ns/default/sa/reviews
+  - address: 10.1.1.2
+    tags:
+      version: v2
+    service_account: spiffe://cluster.local/ns/default/sa/reviews-v2
+- hostname: db.example.com
+  external: mysql.example.com
+  ports:
+  - port: 3306
+`
+
+	// v1 instance is removed, v2 instance is retagged, and a service is added
+	updatedRegistryJSON = `{
+  "services": [{
+    "hostname": "reviews.default.svc.cluster.local",
+    "address": "10.0.0.10",
+    "ports": [
+      {"name": "http", "port": 9080, "protocol": "HTTP"},
+      {"name": "tcp", "port": 9090}
+    ],
+    "instances": [
+      {"address": "10.1.1.2", "tags": {"version": "v3"}}
+    ]
+  }, {
+    "hostname": "db.example.com",
+    "external": "mysql.example.com",
+    "ports": [{"port": 3306}]
+  }, {
+    "hostname": "ratings.default.svc.cluster.local",
+    "ports": [{"port": 8080, "protocol": "HTTP"}]
+  }]
+}`
+
+	invalidRegistryYAML = `
+services:
+- hostname: reviews.default.svc.cluster.local
+  ports:
+  - name: http
+    port: 9080
+  instances:
+  - address: not-an-ip
+`
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func makeController(t *testing.T, content string) (*Controller, string, func()) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "registry.yaml")
+	writeFile(t, path, content)
+	ctl, err := NewController(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctl, path, func() { _ = os.RemoveAll(dir) }
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	ctl, _, cleanup := makeController(t, registryYAML)
+	defer cleanup()
+
+	services := ctl.Services()
+	if len(services) != 2 || services[0].Hostname != "db.example.com" || !services[0].External() {
+		t.Fatalf("got services %v", services)
+	}
+	reviews, ok := ctl.GetService("reviews.default.svc.cluster.local")
+	if !ok {
+		t.Fatal("missing reviews service")
+	}
+	if port, _ := reviews.Ports.Get("tcp"); port.Protocol != model.ProtocolTCP {
+		t.Errorf("got protocol %s, want TCP by default", port.Protocol)
+	}
+
+	instances := ctl.Instances(reviews.Hostname, []string{"http"}, model.TagsList{{"version": "v1"}})
+	if len(instances) != 1 {
+		t.Fatalf("got instances %v, want one", instances)
+	}
+	if endpoint := instances[0].Endpoint; endpoint.Address != "10.1.1.1" || endpoint.Port != 19080 ||
+		endpoint.ServicePort.Port != 9080 {
+		t.Errorf("got endpoint %#v", endpoint)
+	}
+	if got := ctl.Instances(reviews.Hostname, []string{"http", "tcp"}, nil); len(got) != 4 {
+		t.Errorf("got %d instances, want 4", len(got))
+	}
+	if got := ctl.Instances("db.example.com", []string{""}, nil); len(got) != 0 {
+		t.Errorf("got instances %v for an external service", got)
+	}
+
+	hostInstances := ctl.HostInstances(map[string]bool{"10.1.1.2": true})
+	if len(hostInstances) != 2 || hostInstances[0].Tags["version"] != "v2" || hostInstances[1].Endpoint.Port != 9090 {
+		t.Errorf("got host instances %v", hostInstances)
+	}
+
+	accounts := ctl.GetIstioServiceAccounts(reviews.Hostname, []string{"http"})
+	want := []string{
+		"spiffe://cluster.local/ns/default/sa/reviews",
+		"spiffe://cluster.local/ns/default/sa/reviews-v2",
+	}
+	if !reflect.DeepEqual(accounts, want) {
+		t.Errorf("got service accounts %v, want %v", accounts, want)
+	}
+}
+
+func TestInvalidRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "registry.yaml")
+	writeFile(t, path, invalidRegistryYAML)
+	if _, err = NewController(path); err == nil {
+		t.Error("expected error for an invalid registry")
+	}
+	if _, err = NewController(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for a missing registry")
+	}
+}
+
+type event struct {
+	name  string
+	event model.Event
+}
+
+func expectEvents(t *testing.T, events <-chan event, want ...event) {
+	for _, ev := range want {
+		select {
+		case got := <-events:
+			if got != ev {
+				t.Errorf("got event %v, want %v", got, ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %v", ev)
+		}
+	}
+}
+
+func TestControllerEvents(t *testing.T) {
+	ctl, path, cleanup := makeController(t, registryYAML)
+	defer cleanup()
+
+	events := make(chan event, 20)
+	if err := ctl.AppendServiceHandler(func(s *model.Service, ev model.Event) {
+		events <- event{name: s.Hostname, event: ev}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.AppendInstanceHandler(func(i *model.ServiceInstance, ev model.Event) {
+		events <- event{name: i.Endpoint.Address + ":" + i.Endpoint.ServicePort.Name, event: ev}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes made before the watch starts are picked up by the initial reload
+	writeFile(t, path, updatedRegistryJSON)
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	expectEvents(t, events,
+		event{"ratings.default.svc.cluster.local", model.EventAdd},
+		event{"10.1.1.1:http", model.EventDelete},
+		event{"10.1.1.1:tcp", model.EventDelete},
+		event{"10.1.1.2:http", model.EventUpdate},
+		event{"10.1.1.2:tcp", model.EventUpdate})
+	if got := ctl.GetIstioServiceAccounts("reviews.default.svc.cluster.local", []string{"http"}); len(got) != 0 {
+		t.Errorf("got service accounts %v, want none", got)
+	}
+
+	// the last valid content is retained
+	writeFile(t, path, invalidRegistryYAML)
+	time.Sleep(5 * eventDelay)
+	if got := len(ctl.Services()); got != 3 {
+		t.Errorf("got %d services, want the last valid 3", got)
+	}
+	writeFile(t, path, registryYAML)
+	expectEvents(t, events,
+		event{"ratings.default.svc.cluster.local", model.EventDelete},
+		event{"10.1.1.1:http", model.EventAdd},
+		event{"10.1.1.1:tcp", model.EventAdd},
+		event{"10.1.1.2:http", model.EventUpdate},
+		event{"10.1.1.2:tcp", model.EventUpdate})
+	if got := len(ctl.Services()); got != 2 {
+		t.Errorf("got %d services, want 2", got)
+	}
+}
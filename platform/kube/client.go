@@ -243,8 +243,17 @@ func (cl *Client) ConfigDescriptor() model.ConfigDescriptor {
 	return cl.mapping
 }
 
+// configNamespace returns the namespace of the config objects, defaulting to
+// the namespace of the client
+func (cl *Client) configNamespace(namespace string) string {
+	if namespace == "" {
+		return cl.dynNamespace
+	}
+	return namespace
+}
+
 // Get implements registry operation
-func (cl *Client) Get(typ, key string) (proto.Message, bool, string) {
+func (cl *Client) Get(typ, key, namespace string) (proto.Message, bool, string) {
 	// TODO validate
 
 	schema, exists := cl.mapping.GetByType(typ)
@@ -254,7 +263,7 @@ func (cl *Client) Get(typ, key string) (proto.Message, bool, string) {
 
 	config := &Config{}
 	err := cl.dyn.Get().
-		Namespace(cl.dynNamespace).
+		Namespace(cl.configNamespace(namespace)).
 		Resource(IstioKind + "s").
 		Name(configKey(typ, key)).
 		Do().Into(config)
@@ -273,14 +282,14 @@ func (cl *Client) Get(typ, key string) (proto.Message, bool, string) {
 }
 
 // Post implements registry operation
-func (cl *Client) Post(v proto.Message) (string, error) {
+func (cl *Client) Post(v proto.Message, namespace string) (string, error) {
 	// TODO: validate
 	schema, exists := cl.mapping.GetByMessageName(proto.MessageName(v))
 	if !exists {
 		return "", fmt.Errorf("unrecognized message name")
 	}
 
-	out, err := modelToKube(schema, cl.dynNamespace, v)
+	out, err := modelToKube(schema, cl.configNamespace(namespace), v)
 	if err != nil {
 		return "", err
 	}
@@ -299,7 +308,7 @@ func (cl *Client) Post(v proto.Message) (string, error) {
 }
 
 // Put implements registry operation
-func (cl *Client) Put(v proto.Message, revision string) (string, error) {
+func (cl *Client) Put(v proto.Message, namespace, revision string) (string, error) {
 	// TODO: validate
 	schema, exists := cl.mapping.GetByMessageName(proto.MessageName(v))
 	if !exists {
@@ -309,7 +318,7 @@ func (cl *Client) Put(v proto.Message, revision string) (string, error) {
 		return "", fmt.Errorf("revision is required")
 	}
 
-	out, err := modelToKube(schema, cl.dynNamespace, v)
+	out, err := modelToKube(schema, cl.configNamespace(namespace), v)
 	if err != nil {
 		return "", err
 	}
@@ -331,18 +340,18 @@ func (cl *Client) Put(v proto.Message, revision string) (string, error) {
 }
 
 // Delete implements registry operation
-func (cl *Client) Delete(typ, key string) error {
+func (cl *Client) Delete(typ, key, namespace string) error {
 	// TODO: validate
 
 	return cl.dyn.Delete().
-		Namespace(cl.dynNamespace).
+		Namespace(cl.configNamespace(namespace)).
 		Resource(IstioKind + "s").
 		Name(configKey(typ, key)).
 		Do().Error()
 }
 
 // List implements registry operation
-func (cl *Client) List(typ string) ([]model.Config, error) {
+func (cl *Client) List(typ, namespace string) ([]model.Config, error) {
 	_, exists := cl.mapping.GetByType(typ)
 	if !exists {
 		return nil, fmt.Errorf("missing type %q", typ)
@@ -350,7 +359,7 @@ func (cl *Client) List(typ string) ([]model.Config, error) {
 
 	list := &ConfigList{}
 	errs := cl.dyn.Get().
-		Namespace(cl.dynNamespace).
+		Namespace(namespace).
 		Resource(IstioKind + "s").
 		Do().Into(list)
 
@@ -382,10 +391,11 @@ func (cl *Client) convertConfig(item *Config) (model.Config, error) {
 				return model.Config{}, err
 			}
 			return model.Config{
-				Type:     schema.Type,
-				Key:      strings.TrimPrefix(item.Metadata.Name, schema.Type+"-"),
-				Revision: item.Metadata.ResourceVersion,
-				Content:  data,
+				Type:      schema.Type,
+				Key:       strings.TrimPrefix(item.Metadata.Name, schema.Type+"-"),
+				Namespace: item.Metadata.Namespace,
+				Revision:  item.Metadata.ResourceVersion,
+				Content:   data,
 			}, nil
 		}
 	}
@@ -427,9 +437,10 @@ func (cl *Client) GetTLSSecret(uri string) (*model.TLSSecret, error) {
 }
 
 // Request sends requests through the Kubernetes apiserver proxy to
-// the a Kubernetes service.
+// the a Kubernetes service. The header holds additional request headers.
 // (see https://kubernetes.io/docs/concepts/cluster-administration/access-cluster/#discovering-builtin-services)
-func (cl *Client) Request(namespace, service, method, path string, inBody []byte) (int, []byte, error) {
+func (cl *Client) Request(namespace, service, method, path string, inBody []byte,
+	header map[string]string) (int, []byte, error) {
 	// Kubernetes apiserver proxy prefix for the specified namespace and service.
 	absPath := fmt.Sprintf("api/v1/namespaces/%s/services/%s/proxy", namespace, service)
 
@@ -446,10 +457,15 @@ func (cl *Client) Request(namespace, service, method, path string, inBody []byte
 	// API server resource path.
 	absPath += "/" + path
 
-	var status int
-	outBody, err := cl.dyn.Verb(method).
+	request := cl.dyn.Verb(method).
 		AbsPath(absPath).
-		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Type", "application/json")
+	for key, value := range header {
+		request = request.SetHeader(key, value)
+	}
+
+	var status int
+	outBody, err := request.
 		Body(inBody).
 		Do().
 		StatusCode(&status).
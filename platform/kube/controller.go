@@ -45,6 +45,9 @@ type ControllerOptions struct {
 	Namespace    string
 	ResyncPeriod time.Duration
 	DomainSuffix string
+
+	// ClusterID identifies the cluster in the service instances
+	ClusterID string
 }
 
 // Controller is a collection of synchronized resource watchers
@@ -52,6 +55,7 @@ type ControllerOptions struct {
 type Controller struct {
 	mesh         *proxyconfig.ProxyMeshConfig
 	domainSuffix string
+	clusterID    string
 
 	client    *Client
 	queue     Queue
@@ -74,8 +78,9 @@ func NewController(client *Client, mesh *proxyconfig.ProxyMeshConfig, options Co
 	out := &Controller{
 		mesh:         mesh,
 		domainSuffix: options.DomainSuffix,
+		clusterID:    options.ClusterID,
 		client:       client,
-		queue:        NewQueue(1 * time.Second),
+		queue:        NewQueue(options.ClusterID, 1*time.Second),
 		kinds:        make(map[string]cacheHandler),
 	}
 
@@ -113,8 +118,13 @@ func NewController(client *Client, mesh *proxyconfig.ProxyMeshConfig, options Co
 			})
 	}
 
-	// add stores for TPR kinds
-	for _, kind := range []string{IstioKind} {
+	// add stores for TPR kinds unless the client has no config types, as for
+	// clusters that only contribute services
+	kinds := []string{IstioKind}
+	if len(client.mapping) == 0 {
+		kinds = nil
+	}
+	for _, kind := range kinds {
 		out.kinds[kind] = out.createInformer(&Config{}, options.ResyncPeriod,
 			func(opts meta_v1.ListOptions) (result runtime.Object, err error) {
 				result = &ConfigList{}
@@ -160,6 +170,7 @@ func (c *Controller) createInformer(
 	lf cache.ListFunc,
 	wf cache.WatchFunc) cacheHandler {
 	handler := &chainHandler{funcs: []Handler{c.notify}}
+	kind := reflect.TypeOf(o).Elem().Name()
 
 	// TODO: finer-grained index (perf)
 	informer := cache.NewSharedIndexInformer(
@@ -170,14 +181,17 @@ func (c *Controller) createInformer(
 		cache.ResourceEventHandlerFuncs{
 			// TODO: filtering functions to skip over un-referenced resources (perf)
 			AddFunc: func(obj interface{}) {
+				eventCounter.WithLabelValues(c.clusterID, kind, model.EventAdd.String()).Inc()
 				c.queue.Push(Task{handler: handler.apply, obj: obj, event: model.EventAdd})
 			},
 			UpdateFunc: func(old, cur interface{}) {
 				if !reflect.DeepEqual(old, cur) {
+					eventCounter.WithLabelValues(c.clusterID, kind, model.EventUpdate.String()).Inc()
 					c.queue.Push(Task{handler: handler.apply, obj: cur, event: model.EventUpdate})
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
+				eventCounter.WithLabelValues(c.clusterID, kind, model.EventDelete.String()).Inc()
 				c.queue.Push(Task{handler: handler.apply, obj: obj, event: model.EventDelete})
 			},
 		})
@@ -231,9 +245,10 @@ func (c *Controller) appendIngressConfigHandler(k string, f func(model.Config, m
 		messages := convertIngress(*ingress, c.domainSuffix)
 		for key, message := range messages {
 			f(model.Config{
-				Type:    model.IngressRule,
-				Key:     key,
-				Content: message,
+				Type:      model.IngressRule,
+				Key:       key,
+				Namespace: ingress.Namespace,
+				Content:   message,
 			}, ev)
 		}
 
@@ -296,16 +311,16 @@ func (c *Controller) ConfigDescriptor() model.ConfigDescriptor {
 }
 
 // Get implements a registry operation
-func (c *Controller) Get(typ, key string) (proto.Message, bool, string) {
+func (c *Controller) Get(typ, key, namespace string) (proto.Message, bool, string) {
 	switch typ {
 	case model.IngressRule:
-		return c.getIngress(key)
+		return c.getIngress(key, namespace)
 	default:
-		return c.getTPR(typ, key)
+		return c.getTPR(typ, key, namespace)
 	}
 }
 
-func (c *Controller) getTPR(typ, key string) (proto.Message, bool, string) {
+func (c *Controller) getTPR(typ, key, namespace string) (proto.Message, bool, string) {
 	// TODO: validate
 	schema, exists := c.client.mapping.GetByType(typ)
 	if !exists {
@@ -313,7 +328,7 @@ func (c *Controller) getTPR(typ, key string) (proto.Message, bool, string) {
 	}
 
 	store := c.kinds[IstioKind].informer.GetStore()
-	data, exists, err := store.GetByKey(keyFunc(configKey(typ, key), c.client.dynNamespace))
+	data, exists, err := store.GetByKey(keyFunc(configKey(typ, key), c.client.configNamespace(namespace)))
 	if !exists {
 		return nil, false, ""
 	}
@@ -336,7 +351,10 @@ func (c *Controller) getTPR(typ, key string) (proto.Message, bool, string) {
 	return out, true, config.Metadata.ResourceVersion
 }
 
-func (c *Controller) getIngress(key string) (proto.Message, bool, string) {
+// getIngress retrieves an ingress rule by key. Ingress rule keys include the
+// namespace of the ingress resource, which must match the namespace if one is
+// given.
+func (c *Controller) getIngress(key, namespace string) (proto.Message, bool, string) {
 	if c.mesh.IngressControllerMode == proxyconfig.ProxyMeshConfig_OFF {
 		glog.Warningf("Cannot get ingress resource for key %v: ingress resources synchronization is off", key)
 		return nil, false, ""
@@ -347,6 +365,9 @@ func (c *Controller) getIngress(key string) (proto.Message, bool, string) {
 		glog.V(2).Infof("getIngress(%s) => error %v", key, err)
 		return nil, false, ""
 	}
+	if namespace != "" && namespace != ingressNamespace {
+		return nil, false, ""
+	}
 	storeKey := keyFunc(ingressName, ingressNamespace)
 
 	obj, exists, err := c.ingresses.informer.GetStore().GetByKey(storeKey)
@@ -369,31 +390,31 @@ func (c *Controller) getIngress(key string) (proto.Message, bool, string) {
 }
 
 // Post implements a registry operation
-func (c *Controller) Post(val proto.Message) (string, error) {
-	return c.client.Post(val)
+func (c *Controller) Post(val proto.Message, namespace string) (string, error) {
+	return c.client.Post(val, namespace)
 }
 
 // Put implements a registry operation
-func (c *Controller) Put(val proto.Message, revision string) (string, error) {
-	return c.client.Put(val, revision)
+func (c *Controller) Put(val proto.Message, namespace, revision string) (string, error) {
+	return c.client.Put(val, namespace, revision)
 }
 
 // Delete implements a registry operation
-func (c *Controller) Delete(typ, key string) error {
-	return c.client.Delete(typ, key)
+func (c *Controller) Delete(typ, key, namespace string) error {
+	return c.client.Delete(typ, key, namespace)
 }
 
 // List implements a registry operation
-func (c *Controller) List(typ string) ([]model.Config, error) {
+func (c *Controller) List(typ, namespace string) ([]model.Config, error) {
 	switch typ {
 	case model.IngressRule:
-		return c.listIngresses()
+		return c.listIngresses(namespace)
 	default:
-		return c.listTPRs(typ)
+		return c.listTPRs(typ, namespace)
 	}
 }
 
-func (c *Controller) listTPRs(typ string) ([]model.Config, error) {
+func (c *Controller) listTPRs(typ, namespace string) ([]model.Config, error) {
 	if _, ok := c.client.mapping.GetByType(typ); !ok {
 		return nil, fmt.Errorf("missing type %q", typ)
 	}
@@ -402,7 +423,7 @@ func (c *Controller) listTPRs(typ string) ([]model.Config, error) {
 	out := make([]model.Config, 0)
 	for _, data := range c.kinds[IstioKind].informer.GetStore().List() {
 		item, ok := data.(*Config)
-		if ok {
+		if ok && (namespace == model.NamespaceAll || item.Metadata.Namespace == namespace) {
 			config, err := c.client.convertConfig(item)
 			if config.Type == typ {
 				if err != nil {
@@ -416,7 +437,7 @@ func (c *Controller) listTPRs(typ string) ([]model.Config, error) {
 	return out, errs
 }
 
-func (c *Controller) listIngresses() ([]model.Config, error) {
+func (c *Controller) listIngresses(namespace string) ([]model.Config, error) {
 	out := make([]model.Config, 0)
 
 	if c.mesh.IngressControllerMode == proxyconfig.ProxyMeshConfig_OFF {
@@ -426,14 +447,18 @@ func (c *Controller) listIngresses() ([]model.Config, error) {
 
 	for _, obj := range c.ingresses.informer.GetStore().List() {
 		ingress := obj.(*v1beta1.Ingress)
+		if namespace != model.NamespaceAll && ingress.Namespace != namespace {
+			continue
+		}
 		if c.shouldProcessIngress(ingress) {
 			ingressRules := convertIngress(*ingress, c.domainSuffix)
 			for key, message := range ingressRules {
 				out = append(out, model.Config{
-					Type:     model.IngressRule,
-					Key:      key,
-					Revision: ingress.GetResourceVersion(),
-					Content:  message,
+					Type:      model.IngressRule,
+					Key:       key,
+					Namespace: ingress.Namespace,
+					Revision:  ingress.GetResourceVersion(),
+					Content:   message,
 				})
 			}
 		}
@@ -510,6 +535,9 @@ func (c *Controller) Instances(hostname string, ports []string, tagsList model.T
 		}
 	}
 
+	// the instance tags must satisfy one of the tag selectors
+	selectors := tagsList.Selectors()
+
 	// TODO: single port service missing name
 	for _, item := range c.endpoints.informer.GetStore().List() {
 		ep := *item.(*v1.Endpoints)
@@ -519,8 +547,7 @@ func (c *Controller) Instances(hostname string, ports []string, tagsList model.T
 				for _, ea := range ss.Addresses {
 					tags, _ := c.pods.tagsByIP(ea.IP)
 
-					// check that one of the input tags is a subset of the tags
-					if !tagsList.HasSubsetOf(tags) {
+					if !selectors.Matches(tags) {
 						continue
 					}
 
@@ -535,6 +562,8 @@ func (c *Controller) Instances(hostname string, ports []string, tagsList model.T
 								},
 								Service: svc,
 								Tags:    tags,
+								Cluster: c.clusterID,
+								UID:     podUID(ea.TargetRef),
 							})
 						}
 					}
@@ -576,6 +605,8 @@ func (c *Controller) HostInstances(addrs map[string]bool) []*model.ServiceInstan
 							},
 							Service: svc,
 							Tags:    tags,
+							Cluster: c.clusterID,
+							UID:     podUID(ea.TargetRef),
 						})
 					}
 				}
@@ -59,7 +59,7 @@ func TestThirdPartyResourcesClient(t *testing.T) {
 	defer util.DeleteNamespace(cl.client, ns)
 
 	cl.dynNamespace = ns
-	mock.CheckMapInvariant(cl, t, 5)
+	mock.CheckMapInvariant(cl, t, ns, 5)
 
 	// TODO(kuat) initial watch always fails, takes time to register TPR, keep
 	// around as a work-around
@@ -276,10 +276,10 @@ func TestIngressController(t *testing.T) {
 	}
 
 	eventually(func() bool {
-		rules, _ := ctl.List(model.IngressRule)
+		rules, _ := ctl.List(model.IngressRule, ns)
 		return len(rules) == expectedRuleCount
 	}, t)
-	rules, err := ctl.List(model.IngressRule)
+	rules, err := ctl.List(model.IngressRule, ns)
 	if err != nil {
 		t.Errorf("ctl.List(model.IngressRule, %s) => error: %v", ns, err)
 	}
@@ -288,7 +288,7 @@ func TestIngressController(t *testing.T) {
 	}
 
 	for _, listMsg := range rules {
-		getMsg, exists, _ := ctl.Get(model.IngressRule, listMsg.Key)
+		getMsg, exists, _ := ctl.Get(model.IngressRule, listMsg.Key, ns)
 		if !exists {
 			t.Errorf("expected IngressRule with key %v to exist", listMsg.Key)
 
@@ -397,7 +397,7 @@ func TestController(t *testing.T) {
 	})
 	go ctl.Run(stop)
 
-	mock.CheckMapInvariant(cl, t, n)
+	mock.CheckMapInvariant(cl, t, ns, n)
 	glog.Infof("Waiting till all events are received")
 	eventually(func() bool { return added == n && deleted == n }, t)
 }
@@ -423,14 +423,14 @@ func TestControllerCacheFreshness(t *testing.T) {
 
 	// validate cache consistency
 	ctl.RegisterEventHandler(mock.Type, func(config model.Config, ev model.Event) {
-		elts, _ := ctl.List(mock.Type)
+		elts, _ := ctl.List(mock.Type, ns)
 		switch ev {
 		case model.EventAdd:
 			if len(elts) != 1 {
 				t.Errorf("Got %#v, expected %d element(s) on ADD event", elts, 1)
 			}
 			glog.Infof("Calling Delete(%#v)", config.Key)
-			err = ctl.Delete(mock.Type, config.Key)
+			err = ctl.Delete(mock.Type, config.Key, ns)
 			if err != nil {
 				t.Error(err)
 			}
@@ -451,7 +451,7 @@ func TestControllerCacheFreshness(t *testing.T) {
 
 	// add and remove
 	glog.Infof("Calling Post(%#v)", o)
-	if _, err := ctl.Post(o); err != nil {
+	if _, err := ctl.Post(o, ns); err != nil {
 		t.Error(err)
 	}
 	eventually(func() bool {
@@ -478,7 +478,7 @@ func TestControllerClientSync(t *testing.T) {
 	// add elements directly through client
 	for i := 0; i < n; i++ {
 		keys[i] = mock.Make(i)
-		if _, err := cl.Post(keys[i]); err != nil {
+		if _, err := cl.Post(keys[i], ns); err != nil {
 			t.Error(err)
 		}
 	}
@@ -488,36 +488,36 @@ func TestControllerClientSync(t *testing.T) {
 	ctl := NewController(cl, &mesh, ControllerOptions{Namespace: ns, ResyncPeriod: resync})
 	go ctl.Run(stop)
 	eventually(func() bool { return ctl.HasSynced() }, t)
-	os, _ := ctl.List(mock.Type)
+	os, _ := ctl.List(mock.Type, ns)
 	if len(os) != n {
 		t.Errorf("ctl.List => Got %d, expected %d", len(os), n)
 	}
 
 	// remove elements directly through client
 	for i := 0; i < n; i++ {
-		if err := cl.Delete(mock.Type, keys[i].Key); err != nil {
+		if err := cl.Delete(mock.Type, keys[i].Key, ns); err != nil {
 			t.Error(err)
 		}
 	}
 
 	// check again in the controller cache
 	eventually(func() bool {
-		os, _ = ctl.List(mock.Type)
+		os, _ = ctl.List(mock.Type, ns)
 		glog.Infof("ctl.List => Got %d, expected %d", len(os), 0)
 		return len(os) == 0
 	}, t)
 
 	// now add through the controller
 	for i := 0; i < n; i++ {
-		if _, err := ctl.Post(mock.Make(i)); err != nil {
+		if _, err := ctl.Post(mock.Make(i), ns); err != nil {
 			t.Error(err)
 		}
 	}
 
 	// check directly through the client
 	eventually(func() bool {
-		cs, _ := ctl.List(mock.Type)
-		os, _ := cl.List(mock.Type)
+		cs, _ := ctl.List(mock.Type, ns)
+		os, _ := cl.List(mock.Type, ns)
 		glog.Infof("ctl.List => Got %d, expected %d", len(cs), n)
 		glog.Infof("cl.List => Got %d, expected %d", len(os), n)
 		return len(os) == n && len(cs) == n
@@ -525,7 +525,7 @@ func TestControllerClientSync(t *testing.T) {
 
 	// remove elements directly through the client
 	for i := 0; i < n; i++ {
-		if err := cl.Delete(mock.Type, keys[i].Key); err != nil {
+		if err := cl.Delete(mock.Type, keys[i].Key, ns); err != nil {
 			t.Error(err)
 		}
 	}
@@ -761,11 +761,11 @@ func TestIstioConfig(t *testing.T) {
 		},
 	}
 
-	if _, err := cl.Post(rule); err != nil {
+	if _, err := cl.Post(rule, ns); err != nil {
 		t.Errorf("cl.Post() => error %v, want no error", err)
 	}
 
-	out, exists, _ := cl.Get(model.RouteRule, rule.Name)
+	out, exists, _ := cl.Get(model.RouteRule, rule.Name, ns)
 	if !exists {
 		t.Errorf("cl.Get() => missing")
 		return
@@ -778,7 +778,7 @@ func TestIstioConfig(t *testing.T) {
 	registry := model.MakeIstioStore(cl)
 
 	rules := registry.RouteRules()
-	if len(rules) != 1 || !reflect.DeepEqual(rules[rule.Name], rule) {
+	if len(rules) != 1 || !reflect.DeepEqual(rules[model.NamespacedKey(ns, rule.Name)], rule) {
 		t.Errorf("RouteRules() => %v, want %v", rules, rule)
 	}
 
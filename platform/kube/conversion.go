@@ -98,6 +98,14 @@ func convertService(svc v1.Service, domainSuffix string) *model.Service {
 	}
 }
 
+// podUID returns the proxy identity of the pod referenced by an endpoint address
+func podUID(ref *v1.ObjectReference) string {
+	if ref == nil || ref.Kind != "Pod" {
+		return ""
+	}
+	return fmt.Sprintf("kubernetes://%s.%s", ref.Name, ref.Namespace)
+}
+
 // serviceHostname produces FQDN for a k8s service
 func serviceHostname(name, namespace, domainSuffix string) string {
 	return fmt.Sprintf("%s.%s.svc.%s", name, namespace, domainSuffix)
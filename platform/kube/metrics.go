@@ -0,0 +1,46 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "kube",
+		Name:      "events_total",
+		Help:      "Number of informer events by cluster, resource kind and event type.",
+	}, []string{"cluster", "kind", "event"})
+
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "pilot",
+		Subsystem: "kube",
+		Name:      "queue_depth",
+		Help:      "Number of tasks waiting in the controller queue by cluster.",
+	}, []string{"cluster"})
+
+	handlerRetryCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "kube",
+		Name:      "handler_retries_total",
+		Help:      "Number of failed handler executions repeated by the controller queue.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(eventCounter, queueDepth, handlerRetryCounter)
+}
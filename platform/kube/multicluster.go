@@ -0,0 +1,136 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"sort"
+
+	"github.com/golang/glog"
+	multierror "github.com/hashicorp/go-multierror"
+
+	"istio.io/pilot/model"
+)
+
+// MultiClusterController merges the services and instances of several
+// Kubernetes clusters. A service with the same hostname in several clusters is
+// a single service whose instances run in all of them. The service
+// declaration, including the service address, is taken from the first
+// cluster that declares the service.
+type MultiClusterController struct {
+	clusters []*Controller
+}
+
+// NewMultiClusterController creates a controller over the cluster controllers
+// in the order of precedence for service declarations. The controllers should
+// be created with distinct cluster identifiers.
+func NewMultiClusterController(clusters ...*Controller) *MultiClusterController {
+	return &MultiClusterController{clusters: clusters}
+}
+
+// Services implements a service catalog operation
+func (c *MultiClusterController) Services() []*model.Service {
+	seen := make(map[string]bool)
+	out := make([]*model.Service, 0)
+	for _, cluster := range c.clusters {
+		for _, svc := range cluster.Services() {
+			if !seen[svc.Hostname] {
+				seen[svc.Hostname] = true
+				out = append(out, svc)
+			}
+		}
+	}
+	return out
+}
+
+// GetService implements a service catalog operation
+func (c *MultiClusterController) GetService(hostname string) (*model.Service, bool) {
+	for _, cluster := range c.clusters {
+		if svc, exists := cluster.GetService(hostname); exists {
+			return svc, true
+		}
+	}
+	return nil, false
+}
+
+// Instances implements a service catalog operation
+func (c *MultiClusterController) Instances(hostname string, ports []string,
+	tagsList model.TagsList) []*model.ServiceInstance {
+	var out []*model.ServiceInstance
+	for _, cluster := range c.clusters {
+		out = append(out, cluster.Instances(hostname, ports, tagsList)...)
+	}
+	return out
+}
+
+// HostInstances implements a service catalog operation. Pod address ranges
+// may overlap across clusters, so the instances are taken from a single
+// cluster, the first one in the order of precedence that runs instances at the
+// addresses, rather than merged.
+func (c *MultiClusterController) HostInstances(addrs map[string]bool) []*model.ServiceInstance {
+	for _, cluster := range c.clusters {
+		if out := cluster.HostInstances(addrs); len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// GetIstioServiceAccounts returns the Istio service accounts running a
+// service hostname in any of the clusters
+func (c *MultiClusterController) GetIstioServiceAccounts(hostname string, ports []string) []string {
+	saSet := make(map[string]bool)
+	for _, cluster := range c.clusters {
+		for _, sa := range cluster.GetIstioServiceAccounts(hostname, ports) {
+			saSet[sa] = true
+		}
+	}
+	saArray := make([]string, 0, len(saSet))
+	for sa := range saSet {
+		saArray = append(saArray, sa)
+	}
+	sort.Strings(saArray)
+	return saArray
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *MultiClusterController) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	var errs error
+	for _, cluster := range c.clusters {
+		if err := cluster.AppendServiceHandler(f); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *MultiClusterController) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	var errs error
+	for _, cluster := range c.clusters {
+		if err := cluster.AppendInstanceHandler(f); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Run all cluster controllers until a signal is received
+func (c *MultiClusterController) Run(stop <-chan struct{}) {
+	for _, cluster := range c.clusters {
+		go cluster.Run(stop)
+	}
+	<-stop
+	glog.V(2).Info("Multi-cluster controller terminated")
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/pilot/proxy"
+)
+
+func makeClusterController(clusterID string) *Controller {
+	mesh := proxy.DefaultMeshConfig()
+	return NewController(&Client{client: fake.NewSimpleClientset()}, &mesh, ControllerOptions{
+		Namespace:    "default",
+		ResyncPeriod: resync,
+		DomainSuffix: domainSuffix,
+		ClusterID:    clusterID,
+	})
+}
+
+func TestMultiClusterController(t *testing.T) {
+	east := makeClusterController("east")
+	west := makeClusterController("west")
+
+	// svc1 runs in both clusters, svc2 only in the west cluster
+	createPod(east, map[string]string{"app": "prod-app"}, "pod1", "nsA", "acct1", t)
+	createPod(west, map[string]string{"app": "prod-app"}, "pod2", "nsA", "acct2", t)
+	east.pods.keys["128.0.0.1"] = "nsA/pod1"
+	west.pods.keys["129.0.0.1"] = "nsA/pod2"
+
+	createService(east, "svc1", "nsA", []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	createService(west, "svc1", "nsA", []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	createService(west, "svc2", "nsA", []int32{8081}, map[string]string{"app": "prod-app"}, t)
+	createEndpoints(east, "svc1", "nsA", []string{"test-port"}, []string{"128.0.0.1"}, t)
+	createEndpoints(west, "svc1", "nsA", []string{"test-port"}, []string{"129.0.0.1"}, t)
+	createEndpoints(west, "svc2", "nsA", []string{"test-port"}, []string{"129.0.0.1"}, t)
+
+	ctl := NewMultiClusterController(east, west)
+
+	if services := ctl.Services(); len(services) != 2 {
+		t.Errorf("got services %v, want svc1 and svc2", services)
+	}
+	svc2 := serviceHostname("svc2", "nsA", domainSuffix)
+	if _, exists := ctl.GetService(svc2); !exists {
+		t.Errorf("missing service %q", svc2)
+	}
+
+	svc1 := serviceHostname("svc1", "nsA", domainSuffix)
+	instances := ctl.Instances(svc1, []string{"test-port"}, nil)
+	if len(instances) != 2 {
+		t.Fatalf("got instances %v, want one per cluster", instances)
+	}
+	clusters := map[string]string{}
+	for _, instance := range instances {
+		clusters[instance.Endpoint.Address] = instance.Cluster
+	}
+	if want := map[string]string{"128.0.0.1": "east", "129.0.0.1": "west"}; !reflect.DeepEqual(clusters, want) {
+		t.Errorf("got instance clusters %v, want %v", clusters, want)
+	}
+
+	hostInstances := ctl.HostInstances(map[string]bool{"129.0.0.1": true})
+	if len(hostInstances) != 2 {
+		t.Errorf("got host instances %v, want svc1 and svc2 instances", hostInstances)
+	}
+	for _, instance := range hostInstances {
+		if instance.Cluster != "west" {
+			t.Errorf("got instance cluster %q, want west", instance.Cluster)
+		}
+	}
+
+	// overlapping pod addresses are attributed to the first cluster only
+	createEndpoints(east, "svc1", "nsA", []string{"test-port"}, []string{"128.0.0.1", "129.0.0.1"}, t)
+	hostInstances = ctl.HostInstances(map[string]bool{"129.0.0.1": true})
+	if len(hostInstances) != 1 || hostInstances[0].Cluster != "east" {
+		t.Errorf("got host instances %v, want the svc1 instance in east", hostInstances)
+	}
+
+	sa := ctl.GetIstioServiceAccounts(svc1, []string{"test-port"})
+	expected := []string{
+		"spiffe://company.com/ns/nsA/sa/acct1",
+		"spiffe://company.com/ns/nsA/sa/acct2",
+	}
+	if !reflect.DeepEqual(sa, expected) {
+		t.Errorf("Unexpected service accounts %v (expecting %v)", sa, expected)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/util/flowcontrol"
 
 	"istio.io/pilot/model"
@@ -47,15 +48,18 @@ type queueImpl struct {
 	queue   []Task
 	lock    sync.Mutex
 	closing bool
+	depth   prometheus.Gauge
 }
 
-// NewQueue instantiates a queue with a processing function
-func NewQueue(errorDelay time.Duration) Queue {
+// NewQueue instantiates a queue with a processing function for the controller
+// of a cluster
+func NewQueue(clusterID string, errorDelay time.Duration) Queue {
 	return &queueImpl{
 		delay:   errorDelay,
 		queue:   make([]Task, 0),
 		closing: false,
 		lock:    sync.Mutex{},
+		depth:   queueDepth.WithLabelValues(clusterID),
 	}
 }
 
@@ -63,6 +67,7 @@ func (q *queueImpl) Push(item Task) {
 	q.lock.Lock()
 	if !q.closing {
 		q.queue = append(q.queue, item)
+		q.depth.Set(float64(len(q.queue)))
 	}
 	q.lock.Unlock()
 }
@@ -89,11 +94,13 @@ func (q *queueImpl) Run(stop <-chan struct{}) {
 			q.lock.Unlock()
 		} else {
 			item, q.queue = q.queue[0], q.queue[1:]
+			q.depth.Set(float64(len(q.queue)))
 			q.lock.Unlock()
 
 			for {
 				err := item.handler(item.obj, item.event)
 				if err != nil {
+					handlerRetryCounter.Inc()
 					glog.V(2).Infof("Work item failed (%v), repeating after delay %v", err, q.delay)
 					time.Sleep(q.delay)
 				} else {
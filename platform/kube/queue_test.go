@@ -23,7 +23,7 @@ import (
 )
 
 func TestQueue(t *testing.T) {
-	q := NewQueue(1 * time.Microsecond)
+	q := NewQueue("", 1*time.Microsecond)
 	stop := make(chan struct{})
 	out := 0
 	err := true
@@ -50,7 +50,7 @@ func TestQueue(t *testing.T) {
 }
 
 func TestChainedHandler(t *testing.T) {
-	q := NewQueue(1 * time.Microsecond)
+	q := NewQueue("", 1*time.Microsecond)
 	stop := make(chan struct{})
 	out := 0
 	f := func(i int) Handler {
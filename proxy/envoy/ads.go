@@ -0,0 +1,407 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	envoyapi "github.com/envoyproxy/data-plane-api/api"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// Envoy v2 resource type URLs served by ADS
+const (
+	typePrefix   = "type.googleapis.com/envoy.api.v2."
+	clusterType  = typePrefix + "Cluster"
+	endpointType = typePrefix + "ClusterLoadAssignment"
+	routeType    = typePrefix + "RouteConfiguration"
+	listenerType = typePrefix + "Listener"
+)
+
+// typeNames are the short names of the resource types used as metric labels
+var typeNames = map[string]string{
+	clusterType:  CDSName,
+	endpointType: "eds",
+	routeType:    RDSName,
+	listenerType: LDSName,
+}
+
+// pushOrder is the order in which resource types are sent on a push, such
+// that clusters are warmed before the listeners and routes referencing them.
+var pushOrder = []string{clusterType, endpointType, listenerType, routeType}
+
+// adsServer implements the Envoy v2 aggregated discovery service (ADS). Each
+// proxy opens a single bidirectional stream and subscribes to resource types
+// by sending discovery requests. The server responds with the resources of
+// the requested type and pushes a new version of every subscribed type
+// whenever services, instances, or the routing configuration change.
+//
+// Resources are generated by the same code as the REST discovery API.
+// Network filters keep their v1 configuration, so HTTP connection managers
+// continue to fetch routes from RDS over REST.
+type adsServer struct {
+	ds *DiscoveryService
+
+	// version is incremented on every push
+	version uint64 // atomic
+	nonce   uint64 // atomic
+
+	mu          sync.RWMutex
+	connections map[*adsConnection]bool
+}
+
+// adsConnection holds the state of a single proxy stream
+type adsConnection struct {
+	stream envoyapi.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+
+	// pushes signals the stream to regenerate the subscribed resources
+	pushes chan struct{}
+
+	mu      sync.RWMutex
+	node    string
+	watches map[string]*adsWatch
+}
+
+// adsWatch is the subscription of a proxy to a resource type together with
+// the acknowledgement state of the last response
+type adsWatch struct {
+	names []string
+
+	// version and nonce of the last response sent to the proxy
+	version string
+	nonce   string
+
+	// acked is the last version accepted by the proxy
+	acked string
+
+	// nacked is the error reported by the proxy for the last rejected response
+	nacked string
+}
+
+type adsWatchStatus struct {
+	Version string `json:"version"`
+	Acked   string `json:"acked,omitempty"`
+	Nacked  string `json:"nacked,omitempty"`
+}
+
+type adsStatus struct {
+	Nodes map[string]map[string]*adsWatchStatus `json:"ads_status"`
+}
+
+func newADSServer(ds *DiscoveryService) *adsServer {
+	return &adsServer{
+		ds:          ds,
+		connections: make(map[*adsConnection]bool),
+	}
+}
+
+// StreamAggregatedResources implements the ADS gRPC service
+func (s *adsServer) StreamAggregatedResources(
+	stream envoyapi.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	conn := &adsConnection{
+		stream:  stream,
+		pushes:  make(chan struct{}, 1),
+		watches: make(map[string]*adsWatch),
+	}
+	s.mu.Lock()
+	s.connections[conn] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.connections, conn)
+		s.mu.Unlock()
+	}()
+
+	// responses are sent from this goroutine only, requests are received
+	// concurrently to interleave them with pushes
+	requests := make(chan *envoyapi.DiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			request, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case requests <- request:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case request := <-requests:
+			if err := s.process(conn, request); err != nil {
+				return err
+			}
+		case <-conn.pushes:
+			for _, typeURL := range pushOrder {
+				if conn.subscribed(typeURL) {
+					if err := s.send(conn, typeURL); err != nil {
+						return err
+					}
+				}
+			}
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// process handles a discovery request. A request carrying the nonce of the
+// last response is an ACK, or a NACK if it has error details, and triggers a
+// response only if the subscribed resource names change. A request for a type
+// without a watch is a new subscription even if it carries a nonce, since
+// proxies resend their last nonce after reconnecting to another stream.
+func (s *adsServer) process(conn *adsConnection, request *envoyapi.DiscoveryRequest) error {
+	conn.mu.Lock()
+	if conn.node == "" {
+		if request.Node == nil || request.Node.Id == "" {
+			conn.mu.Unlock()
+			return errors.New("missing node identifier")
+		}
+		if cluster := request.Node.Cluster; cluster != s.ds.MeshConfig.IstioServiceCluster {
+			conn.mu.Unlock()
+			return fmt.Errorf("unexpected service cluster %q", cluster)
+		}
+		// node identifier holds the IP address
+		conn.node = request.Node.Id
+	}
+	node := conn.node
+
+	typ, ok := typeNames[request.TypeUrl]
+	if !ok {
+		conn.mu.Unlock()
+		glog.Warningf("ADS: unsupported type %q requested by %s", request.TypeUrl, node)
+		return nil
+	}
+	adsRequestCounter.WithLabelValues(typ).Inc()
+
+	watch, exists := conn.watches[request.TypeUrl]
+	if request.ResponseNonce != "" && exists {
+		if request.ResponseNonce != watch.nonce {
+			conn.mu.Unlock()
+			glog.V(2).Infof("ADS: ignoring stale nonce %q for %s from %s",
+				request.ResponseNonce, request.TypeUrl, node)
+			return nil
+		}
+		if request.ErrorDetail != nil {
+			watch.nacked = request.ErrorDetail.GetMessage()
+			version := watch.version
+			conn.mu.Unlock()
+			glog.Warningf("ADS: %s rejected %s version %s: %s", node, request.TypeUrl, version,
+				request.ErrorDetail.GetMessage())
+			return nil
+		}
+		watch.acked = request.VersionInfo
+		watch.nacked = ""
+		changed := !sameNames(watch.names, request.ResourceNames)
+		watch.names = request.ResourceNames
+		conn.mu.Unlock()
+		if !changed {
+			return nil
+		}
+	} else {
+		if !exists {
+			watch = &adsWatch{}
+			conn.watches[request.TypeUrl] = watch
+		}
+		watch.names = request.ResourceNames
+		conn.mu.Unlock()
+	}
+
+	return s.send(conn, request.TypeUrl)
+}
+
+// send generates and sends the resources of a type to the proxy
+func (s *adsServer) send(conn *adsConnection, typeURL string) error {
+	conn.mu.RLock()
+	node := conn.node
+	names := conn.watches[typeURL].names
+	conn.mu.RUnlock()
+
+	version := strconv.FormatUint(atomic.LoadUint64(&s.version), 10)
+	nonce := strconv.FormatUint(atomic.AddUint64(&s.nonce, 1), 10)
+	response := &envoyapi.DiscoveryResponse{
+		VersionInfo: version,
+		TypeUrl:     typeURL,
+		Nonce:       nonce,
+	}
+
+	start := time.Now()
+	resources, err := s.generate(node, typeURL, names)
+	if err != nil {
+		glog.Warningf("ADS: failed to generate %s for %s: %v", typeURL, node, err)
+	}
+	adsGenerationDuration.WithLabelValues(typeNames[typeURL], nodeType(node)).Observe(time.Since(start).Seconds())
+	for _, resource := range resources {
+		packed, err := ptypes.MarshalAny(resource)
+		if err != nil {
+			return err
+		}
+		response.Resources = append(response.Resources, packed)
+	}
+
+	conn.mu.Lock()
+	watch := conn.watches[typeURL]
+	watch.version = version
+	watch.nonce = nonce
+	conn.mu.Unlock()
+
+	return conn.stream.Send(response)
+}
+
+// generate builds the resources of a type for a proxy node. Resources that
+// fail to translate are omitted from the result.
+func (s *adsServer) generate(node, typeURL string, names []string) ([]proto.Message, error) {
+	var errs error
+	out := make([]proto.Message, 0)
+	switch typeURL {
+	case clusterType:
+		for _, cluster := range s.ds.getClusters(node) {
+			resource, err := buildV2Cluster(cluster)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			out = append(out, resource)
+		}
+
+	case endpointType:
+		// resource names hold service keys
+		for _, name := range names {
+			out = append(out, buildV2ClusterLoadAssignment(name, s.ds.getEndpoints(name)))
+		}
+
+	case listenerType:
+		// ingress and egress proxies use static listeners
+		if node == ingressNode || node == egressNode {
+			break
+		}
+		listeners, _ := buildSidecar(s.ds.proxyContext(node))
+		for _, listener := range listeners {
+			resource, err := buildV2Listener(listener)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			out = append(out, resource)
+		}
+
+	case routeType:
+		// resource names hold listener ports, all route configs are sent if none are named
+		configs := s.ds.getRouteConfigs(node)
+		if len(names) == 0 {
+			ports := make([]int, 0, len(configs))
+			for port := range configs {
+				ports = append(ports, port)
+			}
+			sort.Ints(ports)
+			for _, port := range ports {
+				names = append(names, strconv.Itoa(port))
+			}
+		}
+		for _, name := range names {
+			port, err := strconv.Atoi(name)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("unexpected route config name %q", name))
+				continue
+			}
+			config, ok := configs[port]
+			if !ok {
+				errs = multierror.Append(errs, fmt.Errorf("missing route config for port %d", port))
+				continue
+			}
+			out = append(out, buildV2RouteConfiguration(name, config))
+		}
+	}
+	return out, errs
+}
+
+// push increments the version and signals all streams to resend their
+// subscribed resources
+func (s *adsServer) push() {
+	atomic.AddUint64(&s.version, 1)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for conn := range s.connections {
+		select {
+		case conn.pushes <- struct{}{}:
+		default:
+			// a push is already pending
+		}
+	}
+}
+
+// status reports the acknowledgement state of every subscription per node
+func (s *adsServer) status() map[string]map[string]*adsWatchStatus {
+	out := make(map[string]map[string]*adsWatchStatus)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for conn := range s.connections {
+		conn.mu.RLock()
+		if conn.node != "" {
+			watches, ok := out[conn.node]
+			if !ok {
+				watches = make(map[string]*adsWatchStatus)
+				out[conn.node] = watches
+			}
+			for typeURL, watch := range conn.watches {
+				watches[typeURL] = &adsWatchStatus{
+					Version: watch.version,
+					Acked:   watch.acked,
+					Nacked:  watch.nacked,
+				}
+			}
+		}
+		conn.mu.RUnlock()
+	}
+	return out
+}
+
+func (conn *adsConnection) subscribed(typeURL string) bool {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	_, ok := conn.watches[typeURL]
+	return ok
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
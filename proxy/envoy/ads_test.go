@@ -0,0 +1,278 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	envoyapi "github.com/envoyproxy/data-plane-api/api"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+
+	"istio.io/pilot/adapter/config/memory"
+	"istio.io/pilot/model"
+	"istio.io/pilot/proxy"
+	"istio.io/pilot/test/mock"
+)
+
+// makeADSClient starts an in-process gRPC server for the aggregated discovery
+// service and opens a stream that stands in for Envoy
+func makeADSClient(t *testing.T, ds *DiscoveryService) (
+	envoyapi.AggregatedDiscoveryService_StreamAggregatedResourcesClient, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	envoyapi.RegisterAggregatedDiscoveryServiceServer(server, ds.ads)
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := envoyapi.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream, func() {
+		_ = conn.Close()
+		server.Stop()
+	}
+}
+
+func sendADSRequest(t *testing.T, stream envoyapi.AggregatedDiscoveryService_StreamAggregatedResourcesClient,
+	node string, request *envoyapi.DiscoveryRequest) {
+	mesh := proxy.DefaultMeshConfig()
+	request.Node = &envoyapi.Node{Id: node, Cluster: mesh.IstioServiceCluster}
+	if err := stream.Send(request); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recvADSResponse(t *testing.T, stream envoyapi.AggregatedDiscoveryService_StreamAggregatedResourcesClient,
+	typeURL string) *envoyapi.DiscoveryResponse {
+	response, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TypeUrl != typeURL {
+		t.Fatalf("got type %q, want %q", response.TypeUrl, typeURL)
+	}
+	return response
+}
+
+func TestADSClusters(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+	defer cancel()
+
+	node := mock.HostInstanceV0
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: clusterType})
+	response := recvADSResponse(t, stream, clusterType)
+
+	clusters := ds.getClusters(node)
+	if len(response.Resources) != len(clusters) {
+		t.Fatalf("got %d clusters, want %d", len(response.Resources), len(clusters))
+	}
+	for i, resource := range response.Resources {
+		cluster := &envoyapi.Cluster{}
+		if err := ptypes.UnmarshalAny(resource, cluster); err != nil {
+			t.Fatal(err)
+		}
+		if cluster.Name != clusters[i].Name {
+			t.Errorf("got cluster %q, want %q", cluster.Name, clusters[i].Name)
+		}
+		if clusters[i].Type == SDSName &&
+			(cluster.Type != envoyapi.Cluster_EDS || cluster.EdsClusterConfig.ServiceName != clusters[i].ServiceName) {
+			t.Errorf("cluster %q is not served by EDS: %v", cluster.Name, cluster)
+		}
+	}
+
+	// ACK the response and subscribe to listeners; responses are processed in order
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{
+		TypeUrl:       clusterType,
+		VersionInfo:   response.VersionInfo,
+		ResponseNonce: response.Nonce,
+	})
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: listenerType})
+	listeners := recvADSResponse(t, stream, listenerType)
+	if status := ds.ads.status()[node][clusterType]; status.Acked != response.VersionInfo || status.Nacked != "" {
+		t.Errorf("got status %#v, want ACK for version %q", status, response.VersionInfo)
+	}
+
+	// NACK the listeners
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{
+		TypeUrl:       listenerType,
+		ResponseNonce: listeners.Nonce,
+		ErrorDetail:   &envoyapi.Status{Message: "invalid listener"},
+	})
+	key := mock.HelloService.Key(mock.HelloService.Ports[0], nil)
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: endpointType, ResourceNames: []string{key}})
+	_ = recvADSResponse(t, stream, endpointType)
+
+	// push a new version of all subscribed types in order
+	ds.ads.push()
+	pushed := recvADSResponse(t, stream, clusterType)
+	if pushed.VersionInfo == response.VersionInfo || pushed.Nonce == response.Nonce {
+		t.Errorf("got version %q and nonce %q after push", pushed.VersionInfo, pushed.Nonce)
+	}
+	_ = recvADSResponse(t, stream, endpointType)
+	_ = recvADSResponse(t, stream, listenerType)
+
+	status := ds.ads.status()[node]
+	if status[listenerType].Nacked != "invalid listener" {
+		t.Errorf("got listener status %#v, want NACK", status[listenerType])
+	}
+	if status[clusterType].Version != pushed.VersionInfo {
+		t.Errorf("got cluster status %#v, want version %q", status[clusterType], pushed.VersionInfo)
+	}
+}
+
+func TestADSListeners(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+	defer cancel()
+
+	node := mock.HostInstanceV0
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: listenerType})
+	response := recvADSResponse(t, stream, listenerType)
+
+	listeners, _ := buildSidecar(ds.proxyContext(node))
+	if len(response.Resources) != len(listeners) {
+		t.Fatalf("got %d listeners, want %d", len(response.Resources), len(listeners))
+	}
+	for i, resource := range response.Resources {
+		listener := &envoyapi.Listener{}
+		if err := ptypes.UnmarshalAny(resource, listener); err != nil {
+			t.Fatal(err)
+		}
+		if listener.Name != listeners[i].Name {
+			t.Errorf("got listener %q, want %q", listener.Name, listeners[i].Name)
+		}
+		filters := listener.FilterChains[0].Filters
+		if len(filters) != len(listeners[i].Filters) {
+			t.Errorf("got %d filters in listener %q, want %d", len(filters), listener.Name, len(listeners[i].Filters))
+		}
+	}
+
+	metrics := string(makeDiscoveryRequest(ds, "GET", "/metrics", t))
+	for _, want := range []string{
+		`pilot_ads_requests_total{type="lds"}`,
+		`pilot_ads_generation_duration_seconds_count{node_type="sidecar",type="lds"}`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
+
+func TestADSListenersIngress(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+	defer cancel()
+
+	sendADSRequest(t, stream, ingressNode, &envoyapi.DiscoveryRequest{TypeUrl: listenerType})
+	if response := recvADSResponse(t, stream, listenerType); len(response.Resources) != 0 {
+		t.Errorf("got %d listeners for ingress, want none", len(response.Resources))
+	}
+}
+
+func TestADSEndpointsAndRoutes(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+	defer cancel()
+
+	node := mock.HostInstanceV0
+	key := mock.HelloService.Key(mock.HelloService.Ports[0], nil)
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: endpointType, ResourceNames: []string{key}})
+	response := recvADSResponse(t, stream, endpointType)
+	if len(response.Resources) != 1 {
+		t.Fatalf("got %d endpoint assignments, want 1", len(response.Resources))
+	}
+	assignment := &envoyapi.ClusterLoadAssignment{}
+	if err := ptypes.UnmarshalAny(response.Resources[0], assignment); err != nil {
+		t.Fatal(err)
+	}
+	if assignment.ClusterName != key || len(assignment.Endpoints[0].LbEndpoints) != len(ds.getEndpoints(key)) {
+		t.Errorf("got endpoints %v for %q", assignment, key)
+	}
+
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: routeType, ResourceNames: []string{"80"}})
+	response = recvADSResponse(t, stream, routeType)
+	if len(response.Resources) != 1 {
+		t.Fatalf("got %d route configs, want 1", len(response.Resources))
+	}
+	routes := &envoyapi.RouteConfiguration{}
+	if err := ptypes.UnmarshalAny(response.Resources[0], routes); err != nil {
+		t.Fatal(err)
+	}
+	if want := ds.getRouteConfigs(node)[80]; routes.Name != "80" || len(routes.VirtualHosts) != len(want.VirtualHosts) {
+		t.Errorf("got routes %v, want %d virtual hosts", routes, len(want.VirtualHosts))
+	}
+
+	// ACK with a different set of names triggers a response
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{
+		TypeUrl:       routeType,
+		VersionInfo:   response.VersionInfo,
+		ResponseNonce: response.Nonce,
+		ResourceNames: []string{"80", "81"},
+	})
+	if response = recvADSResponse(t, stream, routeType); len(response.Resources) != 2 {
+		t.Errorf("got %d route configs, want 2", len(response.Resources))
+	}
+}
+
+func TestADSReconnect(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+
+	node := mock.HostInstanceV0
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{TypeUrl: clusterType})
+	response := recvADSResponse(t, stream, clusterType)
+	cancel()
+
+	// a reconnecting proxy resends the nonce it last saw on the old stream
+	stream, cancel = makeADSClient(t, ds)
+	defer cancel()
+	sendADSRequest(t, stream, node, &envoyapi.DiscoveryRequest{
+		TypeUrl:       clusterType,
+		VersionInfo:   response.VersionInfo,
+		ResponseNonce: response.Nonce,
+	})
+	if reconnected := recvADSResponse(t, stream, clusterType); len(reconnected.Resources) != len(response.Resources) {
+		t.Errorf("got %d clusters after reconnect, want %d", len(reconnected.Resources), len(response.Resources))
+	}
+}
+
+func TestADSUnexpectedCluster(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	stream, cancel := makeADSClient(t, ds)
+	defer cancel()
+
+	request := &envoyapi.DiscoveryRequest{
+		TypeUrl: clusterType,
+		Node:    &envoyapi.Node{Id: mock.HostInstanceV0, Cluster: "unknown"},
+	}
+	if err := stream.Send(request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Error("expected stream error for unexpected service cluster")
+	}
+}
@@ -68,9 +68,18 @@ func (conf *Config) Write(w io.Writer) error {
 	return err
 }
 
-// Generate Envoy sidecar proxy configuration
+// Generate Envoy sidecar proxy bootstrap configuration. Listeners and clusters
+// are served by the discovery service (LDS and CDS), except for the passthrough
+// listeners that are specific to the proxy instance.
 func Generate(context *proxy.Context) *Config {
-	mesh := context.MeshConfig
+	instances := context.Discovery.HostInstances(map[string]bool{context.IPAddress: true})
+	listeners, clusters := buildPassthroughListeners(instances, context)
+	return buildConfig(listeners, clusters, true, context.MeshConfig)
+}
+
+// buildSidecar produces the listeners and the referenced clusters for the
+// sidecar proxy served by LDS and CDS
+func buildSidecar(context *proxy.Context) (Listeners, Clusters) {
 	listeners, clusters := buildListeners(context)
 
 	// set bind to port values for port redirection
@@ -80,17 +89,19 @@ func Generate(context *proxy.Context) *Config {
 
 	// add an extra listener that binds to the port that is the recipient of the iptables redirect
 	listeners = append(listeners, &Listener{
-		Address:        fmt.Sprintf("tcp://%s:%d", WildcardAddress, mesh.ProxyListenPort),
+		Name:           VirtualListenerName,
+		Address:        fmt.Sprintf("tcp://%s:%d", WildcardAddress, context.MeshConfig.ProxyListenPort),
 		BindToPort:     true,
 		UseOriginalDst: true,
 		Filters:        make([]*NetworkFilter, 0),
 	})
 
-	return buildConfig(listeners, clusters, mesh)
+	return listeners, clusters
 }
 
 // buildConfig creates a proxy config with discovery services and admin port
-func buildConfig(listeners Listeners, clusters Clusters, mesh *proxyconfig.ProxyMeshConfig) *Config {
+// LDS parameter controls whether to use LDS for the listener updates.
+func buildConfig(listeners Listeners, clusters Clusters, lds bool, mesh *proxyconfig.ProxyMeshConfig) *Config {
 	out := &Config{
 		Listeners: listeners,
 		Admin: Admin{
@@ -112,6 +123,15 @@ func buildConfig(listeners Listeners, clusters Clusters, mesh *proxyconfig.Proxy
 		StatsdUDPIPAddress: mesh.StatsdUdpAddress,
 	}
 
+	if lds {
+		out.ClusterManager.Clusters = append(out.ClusterManager.Clusters,
+			buildCluster(mesh.DiscoveryAddress, LDSName, mesh.ConnectTimeout))
+		out.LDS = &LDSCluster{
+			Cluster:        LDSName,
+			RefreshDelayMs: protoDurationToMS(mesh.DiscoveryRefreshDelay),
+		}
+	}
+
 	if mesh.ZipkinAddress != "" {
 		out.ClusterManager.Clusters = append(out.ClusterManager.Clusters,
 			buildCluster(mesh.ZipkinAddress, ZipkinCollectorCluster, mesh.ConnectTimeout))
@@ -136,16 +156,9 @@ func buildListeners(context *proxy.Context) (Listeners, Clusters) {
 	clusters := append(inClusters, outClusters...)
 
 	// create passthrough listeners if they are missing
-	for _, port := range context.PassthroughPorts {
-		addr := fmt.Sprintf("tcp://%s:%d", context.IPAddress, port)
-		if listeners.GetByAddress(addr) == nil {
-			cluster := buildInboundCluster(port, model.ProtocolTCP, context.MeshConfig.ConnectTimeout)
-			listeners = append(listeners, buildTCPListener(&TCPRouteConfig{
-				Routes: []*TCPRoute{buildTCPRoute(cluster, []string{context.IPAddress})},
-			}, context.IPAddress, port))
-			clusters = append(clusters, cluster)
-		}
-	}
+	passthrough, passthroughClusters := buildPassthroughListeners(instances, context)
+	listeners = append(listeners, passthrough...)
+	clusters = append(clusters, passthroughClusters...)
 
 	// inject static Mixer filter with proxy identities for all HTTP filters
 	if context.MeshConfig.MixerAddress != "" {
@@ -168,6 +181,32 @@ func buildListeners(context *proxy.Context) (Listeners, Clusters) {
 	return listeners, clusters
 }
 
+// buildPassthroughListeners creates TCP listeners for the passthrough ports of
+// the proxy that are not used by the co-located service instances
+func buildPassthroughListeners(instances []*model.ServiceInstance, context *proxy.Context) (Listeners, Clusters) {
+	listeners := make(Listeners, 0)
+	clusters := make(Clusters, 0)
+
+	used := make(map[int]bool)
+	for _, instance := range instances {
+		if instance.Endpoint.Address == context.IPAddress {
+			used[instance.Endpoint.Port] = true
+		}
+	}
+
+	for _, port := range context.PassthroughPorts {
+		if !used[port] {
+			cluster := buildInboundCluster(port, model.ProtocolTCP, context.MeshConfig.ConnectTimeout)
+			listeners = append(listeners, buildTCPListener(&TCPRouteConfig{
+				Routes: []*TCPRoute{buildTCPRoute(cluster, []string{context.IPAddress})},
+			}, context.IPAddress, port))
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	return listeners, clusters
+}
+
 // buildHTTPListener constructs a listener for the network interface address and port
 // Use "0.0.0.0" IP address to listen on all interfaces
 // RDS parameter controls whether to use RDS for the route updates.
@@ -209,6 +248,7 @@ func buildHTTPListener(mesh *proxyconfig.ProxyMeshConfig, routeConfig *HTTPRoute
 	}
 
 	return &Listener{
+		Name:       fmt.Sprintf("http_%s_%d", ip, port),
 		BindToPort: true,
 		Address:    fmt.Sprintf("tcp://%s:%d", ip, port),
 		Filters: []*NetworkFilter{{
@@ -228,9 +268,25 @@ func applyInboundAuth(listener *Listener, mesh *proxyconfig.ProxyMeshConfig) *Li
 	return listener
 }
 
+// applyOutboundAuth sets the SSL context with the service accounts of the
+// destination service on the outbound clusters
+func applyOutboundAuth(clusters Clusters, accounts model.ServiceAccounts, mesh *proxyconfig.ProxyMeshConfig) {
+	switch mesh.AuthPolicy {
+	case proxyconfig.ProxyMeshConfig_NONE:
+	case proxyconfig.ProxyMeshConfig_MUTUAL_TLS:
+		// apply SSL context to enable mutual TLS between Envoy proxies
+		for _, cluster := range clusters {
+			ports := model.PortList{cluster.port}.GetNames()
+			serviceAccounts := accounts.GetIstioServiceAccounts(cluster.hostname, ports)
+			cluster.SSLContext = buildClusterSSLContext(mesh.AuthCertsPath, serviceAccounts)
+		}
+	}
+}
+
 // buildTCPListener constructs a listener for the TCP proxy
 func buildTCPListener(tcpConfig *TCPRouteConfig, ip string, port int) *Listener {
 	return &Listener{
+		Name:    fmt.Sprintf("tcp_%s_%d", ip, port),
 		Address: fmt.Sprintf("tcp://%s:%d", ip, port),
 		Filters: []*NetworkFilter{{
 			Type: "read",
@@ -247,7 +303,7 @@ func buildTCPListener(tcpConfig *TCPRouteConfig, ip string, port int) *Listener
 func buildOutboundListeners(instances []*model.ServiceInstance, services []*model.Service,
 	context *proxy.Context) (Listeners, Clusters) {
 	httpOutbound := buildOutboundHTTPRoutes(instances, services, context.Accounts, context.MeshConfig, context.Config)
-	listeners, clusters := buildOutboundTCPListeners(context.MeshConfig, services)
+	listeners, clusters := buildOutboundTCPListeners(instances, services, context.Accounts, context.MeshConfig, context.Config)
 
 	for port, routeConfig := range httpOutbound {
 		listeners = append(listeners, buildHTTPListener(context.MeshConfig, routeConfig, WildcardAddress, port, true, false))
@@ -379,31 +435,92 @@ func buildOutboundHTTPRoutes(
 // is closed without falling back to the http_connection_manager.
 //
 // Temporary workaround is to add a listener for each service IP that requires
-// TCP routing
-func buildOutboundTCPListeners(mesh *proxyconfig.ProxyMeshConfig, services []*model.Service) (Listeners, Clusters) {
+// TCP routing. Services without an IP address (e.g. headless or Consul
+// services) cannot be matched by destination IP and get no TCP listeners.
+func buildOutboundTCPListeners(
+	instances []*model.ServiceInstance,
+	services []*model.Service,
+	accounts model.ServiceAccounts,
+	mesh *proxyconfig.ProxyMeshConfig,
+	config model.IstioConfigStore) (Listeners, Clusters) {
 	tcpListeners := make(Listeners, 0)
 	tcpClusters := make(Clusters, 0)
+
+	// get all the route rules applicable to the instances
+	rules := config.RouteRulesBySource(instances)
+
 	for _, service := range services {
 		if service.External() {
 			continue // TODO TCP external services not currently supported
 		}
+		if service.Address == "" {
+			continue
+		}
 		for _, servicePort := range service.Ports {
 			switch servicePort.Protocol {
 			case model.ProtocolTCP, model.ProtocolHTTPS:
-				// TODO: Enable SSL context for TCP and HTTPS services.
-				cluster := buildOutboundCluster(service.Hostname, servicePort, nil)
-				route := buildTCPRoute(cluster, []string{service.Address})
-				config := &TCPRouteConfig{Routes: []*TCPRoute{route}}
-				listener := buildTCPListener(config, service.Address, servicePort.Port)
-				tcpClusters = append(tcpClusters, cluster)
+				routes := buildDestinationTCPRoutes(service, servicePort, rules)
+				for _, route := range routes {
+					tcpClusters = append(tcpClusters, route.clusterRef)
+				}
+				routeConfig := &TCPRouteConfig{Routes: routes}
+				listener := buildTCPListener(routeConfig, service.Address, servicePort.Port)
 				tcpListeners = append(tcpListeners, listener)
 			}
 		}
 	}
 	tcpClusters.setTimeout(mesh.ConnectTimeout)
+	applyOutboundAuth(tcpClusters, accounts, mesh)
 	return tcpListeners, tcpClusters
 }
 
+// buildDestinationTCPRoutes lists TCP routes for the service port in the order
+// of the route rule precedence, followed by the default route unless a rule
+// matches all connections. Only rules with L4 match attributes (source service,
+// source tags, or TCP subnets) apply to TCP ports; weighted rules are skipped
+// since the TCP proxy cannot split connections across clusters. The source
+// conditions are matched against the proxy instances when listing the rules.
+func buildDestinationTCPRoutes(service *model.Service,
+	servicePort *model.Port,
+	rules []*proxyconfig.RouteRule) []*TCPRoute {
+	routes := make([]*TCPRoute, 0)
+
+	// collect route rules
+	useDefaultRoute := true
+	for _, rule := range rules {
+		if rule.Destination != service.Hostname {
+			continue
+		}
+
+		match := rule.GetMatch()
+		if match.GetSource() == "" && len(match.GetSourceTags()) == 0 && !hasSubnets(match.GetTcp()) {
+			continue
+		}
+
+		// the TCP proxy can neither match HTTP headers nor split connections
+		if len(match.GetHttpHeaders()) > 0 || len(rule.Route) > 1 {
+			continue
+		}
+
+		route := buildTCPRouteFromRule(rule, service, servicePort)
+		routes = append(routes, route)
+
+		// a rule without subnets matches all connections from the source
+		if !hasSubnets(match.GetTcp()) {
+			useDefaultRoute = false
+			break
+		}
+	}
+
+	if useDefaultRoute {
+		// default route for the destination is always the lowest priority route
+		cluster := buildOutboundCluster(service.Hostname, servicePort, nil)
+		routes = append(routes, buildTCPRoute(cluster, []string{service.Address}))
+	}
+
+	return routes
+}
+
 // buildInboundListeners creates listeners for the server-side (inbound)
 // configuration for co-located service instances. The function also returns
 // all inbound clusters since they are statically declared in the proxy
@@ -454,9 +571,9 @@ func buildInboundListeners(instances []*model.ServiceInstance,
 				applyInboundAuth(buildHTTPListener(mesh, config, endpoint.Address, endpoint.Port, false, false), mesh))
 
 		case model.ProtocolTCP, model.ProtocolHTTPS:
-			listeners = append(listeners, buildTCPListener(&TCPRouteConfig{
+			listeners = append(listeners, applyInboundAuth(buildTCPListener(&TCPRouteConfig{
 				Routes: []*TCPRoute{buildTCPRoute(cluster, []string{endpoint.Address})},
-			}, endpoint.Address, endpoint.Port))
+			}, endpoint.Address, endpoint.Port), mesh))
 
 		default:
 			glog.Warningf("Unsupported inbound protocol %v for port %#v", protocol, servicePort)
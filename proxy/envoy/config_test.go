@@ -83,6 +83,52 @@ func TestRoutesByPath(t *testing.T) {
 	}
 }
 
+func TestDestinationTCPRoutesSourceTags(t *testing.T) {
+	r := memory.Make(model.IstioConfigTypes)
+	rule := &proxyconfig.RouteRule{
+		Name:        "tcp-version",
+		Destination: mock.WorldService.Hostname,
+		Match:       &proxyconfig.MatchCondition{SourceTags: map[string]string{"version": "v0"}},
+		Route:       []*proxyconfig.DestinationWeight{{Tags: map[string]string{"version": "v1"}}},
+	}
+	if _, err := r.Post(rule, ""); err != nil {
+		t.Fatal(err)
+	}
+	config := model.MakeIstioStore(r)
+	port, _ := mock.WorldService.Ports.Get("custom")
+	v1 := buildOutboundCluster(mock.WorldService.Hostname, port, rule.Route[0].Tags)
+	def := buildOutboundCluster(mock.WorldService.Hostname, port, nil)
+
+	cases := []struct {
+		node string
+		want string
+	}{
+		{mock.HostInstanceV0, v1.Name},
+		{mock.HostInstanceV1, def.Name},
+	}
+	for _, c := range cases {
+		instances := mock.Discovery.HostInstances(map[string]bool{c.node: true})
+		routes := buildDestinationTCPRoutes(mock.WorldService, port, config.RouteRulesBySource(instances))
+		if len(routes) != 1 || routes[0].Cluster != c.want {
+			t.Errorf("TCP routes for %s => got %v, want a single route to %s", c.node, routes, c.want)
+		}
+	}
+}
+
+func TestOutboundTCPListenersWithoutAddress(t *testing.T) {
+	// untagged Consul instances register a TCP port on a service without an address
+	service := &model.Service{
+		Hostname: "reviews.service.consul",
+		Ports:    model.PortList{{Name: "tcp-9090", Port: 9090, Protocol: model.ProtocolTCP}},
+	}
+	mesh := proxy.DefaultMeshConfig()
+	config := model.MakeIstioStore(memory.Make(model.IstioConfigTypes))
+	listeners, clusters := buildOutboundTCPListeners(nil, []*model.Service{service}, nil, &mesh, config)
+	if len(listeners) != 0 || len(clusters) != 0 {
+		t.Errorf("got listeners %v and clusters %v, want none", listeners, clusters)
+	}
+}
+
 func TestTCPRouteConfigByRoute(t *testing.T) {
 	cases := []struct {
 		name string
@@ -221,21 +267,24 @@ func TestTCPRouteConfigByRoute(t *testing.T) {
 }
 
 const (
-	envoyV0Config     = "testdata/envoy-v0.json"
-	envoyV0ConfigAuth = "testdata/envoy-v0-auth.json"
-	envoyV1Config     = "testdata/envoy-v1.json"
-	envoyV1ConfigAuth = "testdata/envoy-v1-auth.json"
-	envoyFaultConfig  = "testdata/envoy-fault.json"
-	cbPolicy          = "testdata/cb-policy.yaml.golden"
-	timeoutRouteRule  = "testdata/timeout-route-rule.yaml.golden"
-	weightedRouteRule = "testdata/weighted-route.yaml.golden"
-	faultRouteRule    = "testdata/fault-route.yaml.golden"
-	redirectRouteRule = "testdata/redirect-route.yaml.golden"
-	rewriteRouteRule  = "testdata/rewrite-route.yaml.golden"
+	envoyBootstrap      = "testdata/envoy-bootstrap.json"
+	envoyV0Config       = "testdata/envoy-v0.json"
+	envoyV0ConfigAuth   = "testdata/envoy-v0-auth.json"
+	envoyV1Config       = "testdata/envoy-v1.json"
+	envoyV1ConfigAuth   = "testdata/envoy-v1-auth.json"
+	envoyFaultConfig    = "testdata/envoy-fault.json"
+	envoyTCPRouteConfig = "testdata/envoy-tcp-route.json"
+	cbPolicy            = "testdata/cb-policy.yaml.golden"
+	timeoutRouteRule    = "testdata/timeout-route-rule.yaml.golden"
+	weightedRouteRule   = "testdata/weighted-route.yaml.golden"
+	faultRouteRule      = "testdata/fault-route.yaml.golden"
+	tcpRouteRule        = "testdata/tcp-route.yaml.golden"
+	redirectRouteRule   = "testdata/redirect-route.yaml.golden"
+	rewriteRouteRule    = "testdata/rewrite-route.yaml.golden"
 )
 
-func testConfig(r model.ConfigStore, mesh *proxyconfig.ProxyMeshConfig, instance, envoyConfig string, t *testing.T) {
-	config := Generate(&proxy.Context{
+func makeProxyContext(r model.ConfigStore, mesh *proxyconfig.ProxyMeshConfig, instance string) *proxy.Context {
+	return &proxy.Context{
 		Discovery:  mock.Discovery,
 		Accounts:   mock.Discovery,
 		Config:     model.MakeIstioStore(r),
@@ -244,11 +293,14 @@ func testConfig(r model.ConfigStore, mesh *proxyconfig.ProxyMeshConfig, instance
 		UID:        fmt.Sprintf("uid://%s.my-namespace", instance),
 		// 1090 is deliberately already used by the instances, 3333 requires a new listener
 		PassthroughPorts: []int{1090, 3333},
-	})
-
-	if config == nil {
-		t.Fatal("Failed to generate config")
 	}
+}
+
+// testConfig inlines the listeners and clusters served by LDS and CDS into a
+// static proxy configuration to validate them against the golden artifacts
+func testConfig(r model.ConfigStore, mesh *proxyconfig.ProxyMeshConfig, instance, envoyConfig string, t *testing.T) {
+	listeners, clusters := buildSidecar(makeProxyContext(r, mesh, instance))
+	config := buildConfig(listeners, clusters, false, mesh)
 
 	err := config.WriteFile(envoyConfig)
 	if err != nil {
@@ -275,7 +327,7 @@ func addCircuitBreaker(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -285,7 +337,7 @@ func addRewrite(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -295,7 +347,7 @@ func addRedirect(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -305,7 +357,7 @@ func addTimeout(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -315,7 +367,7 @@ func addWeightedRoute(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -325,7 +377,17 @@ func addFaultRoute(r model.ConfigStore, t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err = r.Post(msg); err != nil {
+	if _, err = r.Post(msg, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addTCPRoute(r model.ConfigStore, t *testing.T) {
+	msg, err := configObjectFromYAML(model.RouteRule, tcpRouteRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Post(msg, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -347,6 +409,21 @@ func TestMockConfig(t *testing.T) {
 	testConfig(r, &mesh, mock.HostInstanceV1, envoyV1Config, t)
 }
 
+func TestGenerateBootstrap(t *testing.T) {
+	r := memory.Make(model.IstioConfigTypes)
+	mesh := makeMeshConfig()
+	config := Generate(makeProxyContext(r, &mesh, mock.HostInstanceV0))
+	if config == nil {
+		t.Fatal("Failed to generate config")
+	}
+
+	if err := config.WriteFile(envoyBootstrap); err != nil {
+		t.Fatal(err)
+	}
+
+	util.CompareYAML(envoyBootstrap, t)
+}
+
 func TestMockConfigWithAuth(t *testing.T) {
 	r := memory.Make(model.IstioConfigTypes)
 	mesh := makeMeshConfig()
@@ -403,3 +480,12 @@ func TestMockConfigFault(t *testing.T) {
 	testConfig(r, &mesh, mock.HostInstanceV0, envoyFaultConfig, t)
 	testConfig(r, &mesh, mock.HostInstanceV1, envoyV1Config, t)
 }
+
+func TestMockConfigTCPRoute(t *testing.T) {
+	r := memory.Make(model.IstioConfigTypes)
+	mesh := makeMeshConfig()
+	addTCPRoute(r, t)
+	// TCP route rule uses source condition, hence the different golden artifacts
+	testConfig(r, &mesh, mock.HostInstanceV0, envoyTCPRouteConfig, t)
+	testConfig(r, &mesh, mock.HostInstanceV1, envoyV1Config, t)
+}
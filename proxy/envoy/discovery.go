@@ -15,17 +15,27 @@
 package envoy
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	restful "github.com/emicklei/go-restful"
+	envoyapi "github.com/envoyproxy/data-plane-api/api"
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
 	proxyconfig "istio.io/api/proxy/v1/config"
 	"istio.io/pilot/model"
@@ -37,15 +47,20 @@ type DiscoveryService struct {
 	*proxy.Context
 	server *http.Server
 
-	// TODO Profile and optimize cache eviction policy to avoid
-	// flushing the entire cache when any route, service, or endpoint
-	// changes. An explicit cache expiration policy should be
-	// considered with this change to avoid memory exhaustion as the
-	// entire cache will no longer be periodically flushed and stale
-	// entries can linger in the cache indefinitely.
+	// ads streams the same configuration over gRPC using the Envoy v2 API
+	ads        *adsServer
+	grpcServer *grpc.Server
+	grpcAddr   string
+
+	// Cached responses are evicted based on the dependencies recorded
+	// with them when services, endpoints, or configuration change.
+	// TODO An explicit cache expiration policy should be considered to
+	// avoid memory exhaustion as stale entries for departed proxies can
+	// linger in the cache indefinitely.
 	sdsCache *discoveryCache
 	cdsCache *discoveryCache
 	rdsCache *discoveryCache
+	ldsCache *discoveryCache
 }
 
 type discoveryCacheStatEntry struct {
@@ -53,29 +68,93 @@ type discoveryCacheStatEntry struct {
 	Miss uint64 `json:"miss"`
 }
 
+type discoveryCacheTypeStatEntry struct {
+	Hit     uint64  `json:"hit"`
+	Miss    uint64  `json:"miss"`
+	HitRate float64 `json:"hit_rate"`
+}
+
 type discoveryCacheStats struct {
-	Stats map[string]*discoveryCacheStatEntry `json:"cache_stats"`
+	Stats map[string]*discoveryCacheStatEntry     `json:"cache_stats"`
+	Types map[string]*discoveryCacheTypeStatEntry `json:"type_stats"`
 }
 
-type discoveryCacheEntry struct {
+// discoveryCacheDeps lists the inputs that a cached response is generated from
+type discoveryCacheDeps struct {
+	// node is the proxy node for CDS, RDS, and LDS responses
+	node string
+
+	// services holds the hostname of the service for SDS responses, and the
+	// hostnames of the services with instances at the proxy node otherwise
+	services map[string]bool
+
+	// destinations holds the hostnames of the outbound clusters
+	destinations map[string]bool
+
+	// rules holds the keys of the route rules that apply to the proxy node
+	rules map[string]bool
+}
+
+// discoveryResponse is a serialized discovery response together with its
+// content hash, used as an entity tag for conditional requests
+type discoveryResponse struct {
 	data []byte
+	etag string
+
+	// gzipped holds the compressed data, computed on the first request that accepts gzip
+	gzipOnce sync.Once
+	gzipped  []byte
+}
+
+func newDiscoveryResponse(data []byte) *discoveryResponse {
+	sum := sha256.Sum256(data)
+	// weak validator since the same tag is used for gzip content encoding
+	return &discoveryResponse{
+		data: data,
+		etag: `W/"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+}
+
+// compressed returns the gzip encoding of the response data
+func (r *discoveryResponse) compressed() []byte {
+	r.gzipOnce.Do(func() {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(r.data); err != nil {
+			glog.Warning(err)
+			return
+		}
+		if err := w.Close(); err != nil {
+			glog.Warning(err)
+			return
+		}
+		r.gzipped = buf.Bytes()
+	})
+	return r.gzipped
+}
+
+type discoveryCacheEntry struct {
+	data *discoveryResponse
+	deps *discoveryCacheDeps
 	hit  uint64 // atomic
 	miss uint64 // atomic
 }
 
 type discoveryCache struct {
+	name     string
 	disabled bool
 	mu       sync.RWMutex
 	cache    map[string]*discoveryCacheEntry
 }
 
-func newDiscoveryCache(enabled bool) *discoveryCache {
+func newDiscoveryCache(name string, enabled bool) *discoveryCache {
 	return &discoveryCache{
+		name:     name,
 		disabled: !enabled,
 		cache:    make(map[string]*discoveryCacheEntry),
 	}
 }
-func (c *discoveryCache) cachedDiscoveryResponse(key string) ([]byte, bool) {
+func (c *discoveryCache) cachedDiscoveryResponse(key string) (*discoveryResponse, bool) {
 	if c.disabled {
 		return nil, false
 	}
@@ -86,17 +165,23 @@ func (c *discoveryCache) cachedDiscoveryResponse(key string) ([]byte, bool) {
 	// Miss - entry.miss is updated in updateCachedDiscoveryResponse
 	entry, ok := c.cache[key]
 	if !ok || entry.data == nil {
+		cacheMissCounter.WithLabelValues(c.name).Inc()
 		return nil, false
 	}
 
 	// Hit
 	atomic.AddUint64(&entry.hit, 1)
+	cacheHitCounter.WithLabelValues(c.name).Inc()
 	return entry.data, true
 }
 
-func (c *discoveryCache) updateCachedDiscoveryResponse(key string, data []byte) {
+// updateCachedDiscoveryResponse stores the response data for the key and
+// returns the response with its entity tag, even if the cache is disabled
+func (c *discoveryCache) updateCachedDiscoveryResponse(key string, deps *discoveryCacheDeps,
+	data []byte) *discoveryResponse {
+	out := newDiscoveryResponse(data)
 	if c.disabled {
-		return
+		return out
 	}
 
 	c.mu.Lock()
@@ -109,8 +194,21 @@ func (c *discoveryCache) updateCachedDiscoveryResponse(key string, data []byte)
 	} else if entry.data != nil {
 		glog.Warningf("Overriding cached data for entry %v", key)
 	}
-	entry.data = data
+	entry.data = out
+	entry.deps = deps
 	atomic.AddUint64(&entry.miss, 1)
+	return out
+}
+
+// evict clears the cached responses with dependencies satisfying the predicate
+func (c *discoveryCache) evict(match func(*discoveryCacheDeps) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range c.cache {
+		if v.data != nil && (v.deps == nil || match(v.deps)) {
+			v.data = nil
+		}
+	}
 }
 
 func (c *discoveryCache) clear() {
@@ -144,6 +242,22 @@ func (c *discoveryCache) stats() map[string]*discoveryCacheStatEntry {
 	return stats
 }
 
+// typeStats aggregates the statistics for all cached responses
+func (c *discoveryCache) typeStats() *discoveryCacheTypeStatEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := &discoveryCacheTypeStatEntry{}
+	for _, v := range c.cache {
+		out.Hit += atomic.LoadUint64(&v.hit)
+		out.Miss += atomic.LoadUint64(&v.miss)
+	}
+	if total := out.Hit + out.Miss; total > 0 {
+		out.HitRate = float64(out.Hit) / float64(total)
+	}
+	return out
+}
+
 type hosts struct {
 	Hosts []*host `json:"hosts"`
 }
@@ -154,6 +268,13 @@ type host struct {
 
 	// Weight is an integer in the range [1, 100] or empty
 	Weight int `json:"load_balancing_weight,omitempty"`
+
+	Tags *hostTags `json:"tags,omitempty"`
+}
+
+type hostTags struct {
+	// AZ holds the cluster running the instance
+	AZ string `json:"az,omitempty"`
 }
 
 type keyAndService struct {
@@ -167,6 +288,27 @@ type nodeAndCluster struct {
 	Clusters       Clusters `json:"clusters"`
 }
 
+type ldsResponse struct {
+	Listeners Listeners `json:"listeners"`
+}
+
+// ConfigDump holds the configuration generated for a proxy node together with
+// the discovery responses and the inputs used to generate them
+type ConfigDump struct {
+	ServiceNode string `json:"service-node"`
+
+	// Config is the bootstrap configuration of the sidecar proxy
+	Config    *Config   `json:"config,omitempty"`
+	Listeners Listeners `json:"listeners,omitempty"`
+
+	Clusters  Clusters           `json:"clusters"`
+	Routes    HTTPRouteConfigs   `json:"routes"`
+	Endpoints map[string][]*host `json:"endpoints"`
+
+	RouteRules    []map[string]interface{} `json:"route_rules"`
+	HostInstances []*model.ServiceInstance `json:"host_instances"`
+}
+
 type routeConfigAndMetadata struct {
 	RouteConfigName string         `json:"route-config-name"`
 	ServiceCluster  string         `json:"service-cluster"`
@@ -186,6 +328,7 @@ const (
 // service instance.
 type DiscoveryServiceOptions struct {
 	Port            int
+	GrpcPort        int
 	EnableProfiling bool
 	EnableCaching   bool
 }
@@ -195,9 +338,10 @@ func NewDiscoveryService(ctl model.Controller, configCache model.ConfigStoreCach
 	o DiscoveryServiceOptions) (*DiscoveryService, error) {
 	out := &DiscoveryService{
 		Context:  context,
-		sdsCache: newDiscoveryCache(o.EnableCaching),
-		cdsCache: newDiscoveryCache(o.EnableCaching),
-		rdsCache: newDiscoveryCache(o.EnableCaching),
+		sdsCache: newDiscoveryCache(SDSName, o.EnableCaching),
+		cdsCache: newDiscoveryCache(CDSName, o.EnableCaching),
+		rdsCache: newDiscoveryCache(RDSName, o.EnableCaching),
+		ldsCache: newDiscoveryCache(LDSName, o.EnableCaching),
 	}
 	container := restful.NewContainer()
 	if o.EnableProfiling {
@@ -210,19 +354,35 @@ func NewDiscoveryService(ctl model.Controller, configCache model.ConfigStoreCach
 	out.Register(container)
 	out.server = &http.Server{Addr: ":" + strconv.Itoa(o.Port), Handler: container}
 
-	// Flush cached discovery responses whenever services, service
-	// instances, or routing configuration changes.
-	serviceHandler := func(s *model.Service, e model.Event) { out.clearCache() }
+	out.ads = newADSServer(out)
+	if o.GrpcPort > 0 {
+		out.grpcServer = grpc.NewServer()
+		envoyapi.RegisterAggregatedDiscoveryServiceServer(out.grpcServer, out.ads)
+		out.grpcAddr = ":" + strconv.Itoa(o.GrpcPort)
+	}
+
+	// Evict affected cached discovery responses and push to streaming proxies
+	// whenever services, service instances, or routing configuration changes.
+	serviceHandler := func(s *model.Service, e model.Event) {
+		out.invalidateService(s)
+		out.ads.push()
+	}
 	if err := ctl.AppendServiceHandler(serviceHandler); err != nil {
 		return nil, err
 	}
-	instanceHandler := func(s *model.ServiceInstance, e model.Event) { out.clearCache() }
+	instanceHandler := func(s *model.ServiceInstance, e model.Event) {
+		out.invalidateInstance(s)
+		out.ads.push()
+	}
 	if err := ctl.AppendInstanceHandler(instanceHandler); err != nil {
 		return nil, err
 	}
 
 	if configCache != nil {
-		configHandler := func(model.Config, model.Event) { out.clearCache() }
+		configHandler := func(c model.Config, e model.Event) {
+			out.invalidateConfig(c)
+			out.ads.push()
+		}
 		configCache.RegisterEventHandler(model.RouteRule, configHandler)
 		configCache.RegisterEventHandler(model.IngressRule, configHandler)
 		configCache.RegisterEventHandler(model.DestinationPolicy, configHandler)
@@ -246,7 +406,7 @@ func (ds *DiscoveryService) Register(container *restful.Container) {
 	// See https://lyft.github.io/envoy/docs/intro/arch_overview/service_discovery.html#arch-overview-service-discovery-sds
 	ws.Route(ws.
 		GET(fmt.Sprintf("/v1/registration/{%s}", ServiceKey)).
-		To(ds.ListEndpoints).
+		To(instrument(SDSName, ds.ListEndpoints)).
 		Doc("SDS registration").
 		Param(ws.PathParameter(ServiceKey, "tuple of service name and tag name").DataType("string")))
 
@@ -260,7 +420,7 @@ func (ds *DiscoveryService) Register(container *restful.Container) {
 	// See https://lyft.github.io/envoy/docs/configuration/cluster_manager/cds.html
 	ws.Route(ws.
 		GET(fmt.Sprintf("/v1/clusters/{%s}/{%s}", ServiceCluster, ServiceNode)).
-		To(ds.ListClusters).
+		To(instrument(CDSName, ds.ListClusters)).
 		Doc("CDS registration").
 		Param(ws.PathParameter(ServiceCluster, "client proxy service cluster").DataType("string")).
 		Param(ws.PathParameter(ServiceNode, "client proxy service node").DataType("string")))
@@ -275,12 +435,29 @@ func (ds *DiscoveryService) Register(container *restful.Container) {
 	// See https://lyft.github.io/envoy/docs/configuration/http_conn_man/rds.html
 	ws.Route(ws.
 		GET(fmt.Sprintf("/v1/routes/{%s}/{%s}/{%s}", RouteConfigName, ServiceCluster, ServiceNode)).
-		To(ds.ListRoutes).
+		To(instrument(RDSName, ds.ListRoutes)).
 		Doc("RDS registration").
 		Param(ws.PathParameter(RouteConfigName, "route configuration name").DataType("string")).
 		Param(ws.PathParameter(ServiceCluster, "client proxy service cluster").DataType("string")).
 		Param(ws.PathParameter(ServiceNode, "client proxy service node").DataType("string")))
 
+	// This route makes discovery act as an Envoy Listener discovery service (LDS).
+	// See https://lyft.github.io/envoy/docs/configuration/listeners/lds.html
+	ws.Route(ws.
+		GET(fmt.Sprintf("/v1/listeners/{%s}/{%s}", ServiceCluster, ServiceNode)).
+		To(instrument(LDSName, ds.ListListeners)).
+		Doc("LDS registration").
+		Param(ws.PathParameter(ServiceCluster, "client proxy service cluster").DataType("string")).
+		Param(ws.PathParameter(ServiceNode, "client proxy service node").DataType("string")))
+
+	// Dump the configuration generated for a proxy (informational, not invoked by Envoy)
+	ws.Route(ws.
+		GET(fmt.Sprintf("/v1/config_dump/{%s}", ServiceNode)).
+		To(ds.GetConfigDump).
+		Doc("Configuration generated for a proxy").
+		Param(ws.PathParameter(ServiceNode, "client proxy service node").DataType("string")).
+		Writes(ConfigDump{}))
+
 	ws.Route(ws.
 		GET(fmt.Sprintf("/v1alpha/secret/{%s}/{%s}", ServiceCluster, ServiceNode)).
 		To(ds.ListSecret).
@@ -299,30 +476,52 @@ func (ds *DiscoveryService) Register(container *restful.Container) {
 		To(ds.ClearCacheStats).
 		Doc("Clear discovery service cache stats"))
 
+	ws.Route(ws.
+		GET("/ads_status").
+		To(ds.GetADSStatus).
+		Doc("Get aggregated discovery service subscriptions and acknowledgements per node").
+		Writes(adsStatus{}))
+
 	container.Add(ws)
+
+	// Export metrics for Prometheus
+	container.ServeMux.Handle("/metrics", promhttp.Handler())
 }
 
 // Run starts the server and blocks
 func (ds *DiscoveryService) Run() {
+	if ds.grpcServer != nil {
+		go func() {
+			glog.Infof("Starting aggregated discovery service at %v", ds.grpcAddr)
+			listener, err := net.Listen("tcp", ds.grpcAddr)
+			if err != nil {
+				glog.Warning(err)
+				return
+			}
+			if err = ds.grpcServer.Serve(listener); err != nil {
+				glog.Warning(err)
+			}
+		}()
+	}
+
 	glog.Infof("Starting discovery service at %v", ds.server.Addr)
 	if err := ds.server.ListenAndServe(); err != nil {
 		glog.Warning(err)
 	}
 }
 
-// GetCacheStats returns the statistics for cached discovery responses.
+// GetCacheStats returns the statistics for cached discovery responses and the
+// hit rates per discovery type.
 func (ds *DiscoveryService) GetCacheStats(_ *restful.Request, response *restful.Response) {
 	stats := make(map[string]*discoveryCacheStatEntry)
-	for k, v := range ds.sdsCache.stats() {
-		stats[k] = v
-	}
-	for k, v := range ds.cdsCache.stats() {
-		stats[k] = v
-	}
-	for k, v := range ds.rdsCache.stats() {
-		stats[k] = v
+	types := make(map[string]*discoveryCacheTypeStatEntry)
+	for _, cache := range []*discoveryCache{ds.sdsCache, ds.cdsCache, ds.rdsCache, ds.ldsCache} {
+		for k, v := range cache.stats() {
+			stats[k] = v
+		}
+		types[cache.name] = cache.typeStats()
 	}
-	if err := response.WriteEntity(discoveryCacheStats{stats}); err != nil {
+	if err := response.WriteEntity(discoveryCacheStats{Stats: stats, Types: types}); err != nil {
 		glog.Warning(err)
 	}
 }
@@ -332,6 +531,14 @@ func (ds *DiscoveryService) ClearCacheStats(_ *restful.Request, _ *restful.Respo
 	ds.sdsCache.resetStats()
 	ds.cdsCache.resetStats()
 	ds.rdsCache.resetStats()
+	ds.ldsCache.resetStats()
+}
+
+// GetADSStatus returns the state of the aggregated discovery streams.
+func (ds *DiscoveryService) GetADSStatus(_ *restful.Request, response *restful.Response) {
+	if err := response.WriteEntity(adsStatus{ds.ads.status()}); err != nil {
+		glog.Warning(err)
+	}
 }
 
 func (ds *DiscoveryService) clearCache() {
@@ -339,6 +546,87 @@ func (ds *DiscoveryService) clearCache() {
 	ds.sdsCache.clear()
 	ds.cdsCache.clear()
 	ds.rdsCache.clear()
+	ds.ldsCache.clear()
+}
+
+// invalidateService evicts the endpoints of the service. Service ports
+// determine the outbound listeners, clusters, and routes of every proxy.
+func (ds *DiscoveryService) invalidateService(svc *model.Service) {
+	glog.V(2).Infof("Evicting discovery responses for service %s", svc.Hostname)
+	ds.sdsCache.evict(func(deps *discoveryCacheDeps) bool { return deps.services[svc.Hostname] })
+	ds.cdsCache.clear()
+	ds.rdsCache.clear()
+	ds.ldsCache.clear()
+}
+
+// invalidateInstance evicts the endpoints of the service and the responses for
+// the proxy nodes that hosted or host the service instances. The instance may
+// not carry an endpoint since the registries aggregate endpoints per service.
+func (ds *DiscoveryService) invalidateInstance(instance *model.ServiceInstance) {
+	hostname := instance.Service.Hostname
+	glog.V(2).Infof("Evicting discovery responses for instances of service %s", hostname)
+	nodes := make(map[string]bool)
+	if instance.Endpoint.Address != "" {
+		nodes[instance.Endpoint.Address] = true
+	}
+	for _, current := range ds.Discovery.Instances(hostname, instance.Service.Ports.GetNames(), nil) {
+		nodes[current.Endpoint.Address] = true
+	}
+
+	ds.sdsCache.evict(func(deps *discoveryCacheDeps) bool { return deps.services[hostname] })
+	match := func(deps *discoveryCacheDeps) bool { return nodes[deps.node] || deps.services[hostname] }
+	ds.cdsCache.evict(match)
+	ds.rdsCache.evict(match)
+	ds.ldsCache.evict(match)
+}
+
+// invalidateConfig evicts the responses that depend on the configuration
+// object. Route rules affect the nodes they applied to before the change or
+// apply to after the change, ingress rules affect the ingress proxy, and
+// destination policies affect the clusters for the destination.
+func (ds *DiscoveryService) invalidateConfig(config model.Config) {
+	glog.V(2).Infof("Evicting discovery responses for %s %s", config.Type, config.Key)
+	switch config.Type {
+	case model.RouteRule:
+		rule, _ := config.Content.(*proxyconfig.RouteRule)
+		instances := make(map[string][]*model.ServiceInstance)
+		match := func(deps *discoveryCacheDeps) bool {
+			if deps.rules[model.NamespacedKey(config.Namespace, config.Key)] {
+				return true
+			}
+			if rule == nil {
+				return false
+			}
+			// skip computing instances if the rule source is not at the node
+			if rule.Match != nil && rule.Match.Source != "" && !deps.services[rule.Match.Source] {
+				return false
+			}
+			hostInstances, ok := instances[deps.node]
+			if !ok {
+				hostInstances = ds.Discovery.HostInstances(map[string]bool{deps.node: true})
+				instances[deps.node] = hostInstances
+			}
+			return model.MatchSource(rule, hostInstances)
+		}
+		ds.cdsCache.evict(match)
+		ds.rdsCache.evict(match)
+		ds.ldsCache.evict(match)
+
+	case model.IngressRule:
+		match := func(deps *discoveryCacheDeps) bool { return deps.node == ingressNode }
+		ds.cdsCache.evict(match)
+		ds.rdsCache.evict(match)
+
+	case model.DestinationPolicy:
+		if policy, ok := config.Content.(*proxyconfig.DestinationPolicy); ok {
+			ds.cdsCache.evict(func(deps *discoveryCacheDeps) bool { return deps.destinations[policy.Destination] })
+		} else {
+			ds.cdsCache.clear()
+		}
+
+	default:
+		ds.clearCache()
+	}
 }
 
 // ListAllEndpoints responds with all Services and is not restricted to a single service-key
@@ -376,23 +664,20 @@ func (ds *DiscoveryService) ListEndpoints(request *restful.Request, response *re
 	key := request.Request.URL.String()
 	out, cached := ds.sdsCache.cachedDiscoveryResponse(key)
 	if !cached {
-		hostname, ports, tags := model.ParseServiceKey(request.PathParameter(ServiceKey))
-		// envoy expects an empty array if no hosts are available
-		hostArray := make([]*host, 0)
-		for _, ep := range ds.Discovery.Instances(hostname, ports.GetNames(), tags) {
-			hostArray = append(hostArray, &host{
-				Address: ep.Endpoint.Address,
-				Port:    ep.Endpoint.Port,
-			})
-		}
-		var err error
-		if out, err = json.MarshalIndent(hosts{Hosts: hostArray}, " ", " "); err != nil {
+		start := time.Now()
+		serviceKey := request.PathParameter(ServiceKey)
+		hostArray := ds.getEndpoints(serviceKey)
+		data, err := json.MarshalIndent(hosts{Hosts: hostArray}, " ", " ")
+		if err != nil {
 			errorResponse(response, http.StatusInternalServerError, err.Error())
 			return
 		}
-		ds.sdsCache.updateCachedDiscoveryResponse(key, out)
+		hostname, _, _ := model.ParseServiceKey(serviceKey)
+		deps := &discoveryCacheDeps{services: map[string]bool{hostname: true}}
+		observeGeneration(SDSName, "", start)
+		out = ds.sdsCache.updateCachedDiscoveryResponse(key, deps, data)
 	}
-	writeResponse(response, out)
+	writeDiscoveryResponse(request, response, out)
 }
 
 // ListAllClusters responds to CDS requests that are not limited by a service-cluster and service-node
@@ -424,6 +709,7 @@ func (ds *DiscoveryService) ListClusters(request *restful.Request, response *res
 	key := request.Request.URL.String()
 	out, cached := ds.cdsCache.cachedDiscoveryResponse(key)
 	if !cached {
+		start := time.Now()
 		if sc := request.PathParameter(ServiceCluster); sc != ds.MeshConfig.IstioServiceCluster {
 			errorResponse(response, http.StatusNotFound,
 				fmt.Sprintf("Unexpected %s %q", ServiceCluster, sc))
@@ -434,14 +720,22 @@ func (ds *DiscoveryService) ListClusters(request *restful.Request, response *res
 		node := request.PathParameter(ServiceNode)
 		clusters := ds.getClusters(node)
 
-		var err error
-		if out, err = json.MarshalIndent(ClusterManager{Clusters: clusters}, " ", " "); err != nil {
+		data, err := json.MarshalIndent(ClusterManager{Clusters: clusters}, " ", " ")
+		if err != nil {
 			errorResponse(response, http.StatusInternalServerError, err.Error())
 			return
 		}
-		ds.cdsCache.updateCachedDiscoveryResponse(key, out)
+		deps := ds.nodeDeps(node)
+		deps.destinations = make(map[string]bool)
+		for _, cluster := range clusters {
+			if cluster.hostname != "" {
+				deps.destinations[cluster.hostname] = true
+			}
+		}
+		observeGeneration(CDSName, node, start)
+		out = ds.cdsCache.updateCachedDiscoveryResponse(key, deps, data)
 	}
-	writeResponse(response, out)
+	writeDiscoveryResponse(request, response, out)
 }
 
 // ListAllRoutes responds to RDS requests that are not limited by a route-config, service-cluster, nor service-node
@@ -482,6 +776,7 @@ func (ds *DiscoveryService) ListRoutes(request *restful.Request, response *restf
 	key := request.Request.URL.String()
 	out, cached := ds.rdsCache.cachedDiscoveryResponse(key)
 	if !cached {
+		start := time.Now()
 		if sc := request.PathParameter(ServiceCluster); sc != ds.MeshConfig.IstioServiceCluster {
 			errorResponse(response, http.StatusNotFound,
 				fmt.Sprintf("Unexpected %s %q", ServiceCluster, sc))
@@ -508,11 +803,63 @@ func (ds *DiscoveryService) ListRoutes(request *restful.Request, response *restf
 				fmt.Sprintf("Missing route config for port %d", port))
 			return
 		}
-		if out, err = json.MarshalIndent(routeConfig, " ", " "); err != nil {
+		data, err := json.MarshalIndent(routeConfig, " ", " ")
+		if err != nil {
 			errorResponse(response, http.StatusInternalServerError, err.Error())
 			return
 		}
-		ds.rdsCache.updateCachedDiscoveryResponse(key, out)
+		observeGeneration(RDSName, node, start)
+		out = ds.rdsCache.updateCachedDiscoveryResponse(key, ds.nodeDeps(node), data)
+	}
+	writeDiscoveryResponse(request, response, out)
+}
+
+// ListListeners responds to LDS requests for the sidecar proxies. Ingress and
+// egress proxies use static listeners.
+func (ds *DiscoveryService) ListListeners(request *restful.Request, response *restful.Response) {
+	key := request.Request.URL.String()
+	out, cached := ds.ldsCache.cachedDiscoveryResponse(key)
+	if !cached {
+		start := time.Now()
+		if sc := request.PathParameter(ServiceCluster); sc != ds.MeshConfig.IstioServiceCluster {
+			errorResponse(response, http.StatusNotFound,
+				fmt.Sprintf("Unexpected %s %q", ServiceCluster, sc))
+			return
+		}
+
+		// service-node holds the IP address
+		node := request.PathParameter(ServiceNode)
+		if node == ingressNode || node == egressNode {
+			errorResponse(response, http.StatusNotFound,
+				fmt.Sprintf("Unexpected %s %q", ServiceNode, node))
+			return
+		}
+
+		listeners, _ := buildSidecar(ds.proxyContext(node))
+
+		data, err := json.MarshalIndent(ldsResponse{Listeners: listeners}, " ", " ")
+		if err != nil {
+			errorResponse(response, http.StatusInternalServerError, err.Error())
+			return
+		}
+		observeGeneration(LDSName, node, start)
+		out = ds.ldsCache.updateCachedDiscoveryResponse(key, ds.nodeDeps(node), data)
+	}
+	writeDiscoveryResponse(request, response, out)
+}
+
+// GetConfigDump responds with the configuration generated for a proxy node
+func (ds *DiscoveryService) GetConfigDump(request *restful.Request, response *restful.Response) {
+	// service-node holds the IP address
+	dump, err := ds.configDump(request.PathParameter(ServiceNode))
+	if err != nil {
+		errorResponse(response, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out, err := json.MarshalIndent(dump, " ", " ")
+	if err != nil {
+		errorResponse(response, http.StatusInternalServerError, err.Error())
+		return
 	}
 	writeResponse(response, out)
 }
@@ -550,6 +897,62 @@ func writeResponse(r *restful.Response, data []byte) {
 	}
 }
 
+// writeDiscoveryResponse writes the response with its entity tag. Requests
+// with a matching If-None-Match header receive 304 Not Modified without a
+// body, and the data is gzip encoded if the client accepts it.
+func writeDiscoveryResponse(request *restful.Request, r *restful.Response, out *discoveryResponse) {
+	r.AddHeader("ETag", out.etag)
+	r.AddHeader("Vary", "Accept-Encoding")
+	if etagMatch(request.HeaderParameter("If-None-Match"), out.etag) {
+		r.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if acceptsGzip(request.HeaderParameter("Accept-Encoding")) {
+		if data := out.compressed(); data != nil {
+			r.AddHeader("Content-Encoding", "gzip")
+			writeResponse(r, data)
+			return
+		}
+	}
+	writeResponse(r, out.data)
+}
+
+// etagMatch checks whether an If-None-Match header value matches the entity
+// tag using the weak comparison function
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip checks whether an Accept-Encoding header value allows gzip
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		params := strings.Split(coding, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		// a zero quality value explicitly refuses the coding
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[len("q="):], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // List all service nodes (typically proxy IPv4 addresses)
 func (ds *DiscoveryService) allServiceNodes() []string {
 	// Gather service nodes
@@ -573,6 +976,23 @@ func (ds *DiscoveryService) allServiceNodes() []string {
 	return serviceNodes
 }
 
+func (ds *DiscoveryService) getEndpoints(serviceKey string) []*host {
+	hostname, ports, tags := model.ParseServiceKey(serviceKey)
+	// envoy expects an empty array if no hosts are available
+	out := make([]*host, 0)
+	for _, ep := range ds.Discovery.Instances(hostname, ports.GetNames(), tags) {
+		h := &host{
+			Address: ep.Endpoint.Address,
+			Port:    ep.Endpoint.Port,
+		}
+		if ep.Cluster != "" {
+			h.Tags = &hostTags{AZ: ep.Cluster}
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
 func (ds *DiscoveryService) getClusters(node string) Clusters {
 	// CDS computes clusters that are referenced by RDS routes for a particular proxy node
 	// TODO: this implementation is inefficient as it is recomputing all the routes for all proxies
@@ -604,21 +1024,92 @@ func (ds *DiscoveryService) getClusters(node string) Clusters {
 		}
 
 		// apply auth policies
-		switch ds.MeshConfig.AuthPolicy {
-		case proxyconfig.ProxyMeshConfig_NONE:
-		case proxyconfig.ProxyMeshConfig_MUTUAL_TLS:
-			// apply SSL context to enable mutual TLS between Envoy proxies
-			for _, cluster := range clusters {
-				ports := model.PortList{cluster.port}.GetNames()
-				serviceAccounts := ds.Accounts.GetIstioServiceAccounts(cluster.hostname, ports)
-				cluster.SSLContext = buildClusterSSLContext(ds.MeshConfig.AuthCertsPath, serviceAccounts)
-			}
-		}
+		applyOutboundAuth(clusters, ds.Accounts, ds.MeshConfig)
+	}
+
+	// sidecar listeners served by LDS reference statically declared clusters
+	if node != ingressNode && node != egressNode {
+		_, listenerClusters := buildSidecar(ds.proxyContext(node))
+		clusters = append(clusters, listenerClusters...).normalize()
 	}
 
 	return clusters
 }
 
+// configDump collects the configuration generated for a proxy node. Ingress
+// and egress proxies use static listeners and bootstrap configuration.
+func (ds *DiscoveryService) configDump(node string) (*ConfigDump, error) {
+	instances := ds.Discovery.HostInstances(map[string]bool{node: true})
+	out := &ConfigDump{
+		ServiceNode:   node,
+		Clusters:      ds.getClusters(node),
+		Routes:        ds.getRouteConfigs(node),
+		Endpoints:     make(map[string][]*host),
+		RouteRules:    make([]map[string]interface{}, 0),
+		HostInstances: instances,
+	}
+
+	if node != ingressNode && node != egressNode {
+		context := ds.proxyContext(node)
+		out.Config = Generate(context)
+		out.Listeners, _ = buildSidecar(context)
+	}
+
+	for _, cluster := range out.Clusters {
+		if cluster.Type == SDSName {
+			out.Endpoints[cluster.ServiceName] = ds.getEndpoints(cluster.ServiceName)
+		}
+	}
+
+	schema, ok := model.IstioConfigTypes.GetByType(model.RouteRule)
+	if !ok {
+		return nil, fmt.Errorf("missing schema for %q", model.RouteRule)
+	}
+	for _, rule := range ds.Config.RouteRulesBySource(instances) {
+		data, err := schema.ToJSONMap(rule)
+		if err != nil {
+			return nil, err
+		}
+		out.RouteRules = append(out.RouteRules, data)
+	}
+
+	return out, nil
+}
+
+// nodeDeps returns the dependencies of the responses for the proxy node: the
+// services with instances at the node and the route rules applied to them.
+func (ds *DiscoveryService) nodeDeps(node string) *discoveryCacheDeps {
+	instances := ds.Discovery.HostInstances(map[string]bool{node: true})
+	deps := &discoveryCacheDeps{
+		node:     node,
+		services: make(map[string]bool),
+		rules:    make(map[string]bool),
+	}
+	for _, instance := range instances {
+		deps.services[instance.Service.Hostname] = true
+	}
+	for key, rule := range ds.Config.RouteRules() {
+		if model.MatchSource(rule, instances) {
+			deps.rules[key] = true
+		}
+	}
+	return deps
+}
+
+// proxyContext returns the context of the sidecar proxy for the service node.
+// The platform UID of the proxy is taken from its service instances.
+func (ds *DiscoveryService) proxyContext(node string) *proxy.Context {
+	context := *ds.Context
+	context.IPAddress = node
+	for _, instance := range ds.Discovery.HostInstances(map[string]bool{node: true}) {
+		if instance.UID != "" {
+			context.UID = instance.UID
+			break
+		}
+	}
+	return &context
+}
+
 func (ds *DiscoveryService) getRouteConfigs(node string) (httpRouteConfigs HTTPRouteConfigs) {
 
 	switch node {
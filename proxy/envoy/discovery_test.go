@@ -15,11 +15,16 @@
 package envoy
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	restful "github.com/emicklei/go-restful"
 
@@ -121,6 +126,34 @@ func TestServiceDiscovery(t *testing.T) {
 	compareResponse(response, "testdata/sds.json", t)
 }
 
+// clusterDiscovery labels the mock instances with a cluster
+type clusterDiscovery struct {
+	*mock.ServiceDiscovery
+	cluster string
+}
+
+func (sd clusterDiscovery) Instances(hostname string, ports []string, tags model.TagsList) []*model.ServiceInstance {
+	out := sd.ServiceDiscovery.Instances(hostname, ports, tags)
+	for _, instance := range out {
+		instance.Cluster = sd.cluster
+	}
+	return out
+}
+
+func TestServiceDiscoveryClusterZone(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	ds.Discovery = clusterDiscovery{ServiceDiscovery: mock.Discovery, cluster: "west"}
+	hosts := ds.getEndpoints(mock.HelloService.Key(mock.HelloService.Ports[0], nil))
+	if len(hosts) == 0 {
+		t.Fatal("expected hosts")
+	}
+	for _, h := range hosts {
+		if h.Tags == nil || h.Tags.AZ != "west" {
+			t.Errorf("got tags %#v for host %s, want zone %q", h.Tags, h.Address, "west")
+		}
+	}
+}
+
 // Can we list Services?
 func TestServiceDiscoveryListAllServices(t *testing.T) {
 	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
@@ -312,6 +345,78 @@ func TestRouteDiscoveryEgress(t *testing.T) {
 	compareResponse(response, "testdata/rds-egress.json", t)
 }
 
+func TestListenerDiscoverySidecar(t *testing.T) {
+	registry := memory.Make(model.IstioConfigTypes)
+	ds := makeDiscoveryService(t, registry)
+	url := fmt.Sprintf("/v1/listeners/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV0)
+	response := makeDiscoveryRequest(ds, "GET", url, t)
+	compareResponse(response, "testdata/lds-v0.json", t)
+	url = fmt.Sprintf("/v1/listeners/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV1)
+	response = makeDiscoveryRequest(ds, "GET", url, t)
+	compareResponse(response, "testdata/lds-v1.json", t)
+}
+
+// uidDiscovery assigns a workload identity to the mock host instances
+type uidDiscovery struct {
+	*mock.ServiceDiscovery
+}
+
+func (sd uidDiscovery) HostInstances(addrs map[string]bool) []*model.ServiceInstance {
+	out := sd.ServiceDiscovery.HostInstances(addrs)
+	for _, instance := range out {
+		instance.UID = "kubernetes://" + instance.Endpoint.Address + ".default"
+	}
+	return out
+}
+
+func TestListenerDiscoveryMixerUID(t *testing.T) {
+	registry := memory.Make(model.IstioConfigTypes)
+	ds := makeDiscoveryService(t, registry)
+	ds.Discovery = uidDiscovery{ServiceDiscovery: mock.Discovery}
+	ds.MeshConfig.MixerAddress = "localhost:9091"
+	url := fmt.Sprintf("/v1/listeners/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV0)
+	response := string(makeDiscoveryRequest(ds, "GET", url, t))
+	uid := "kubernetes://" + mock.HostInstanceV0 + ".default"
+	for _, attribute := range []string{"target.uid", "source.uid"} {
+		if want := fmt.Sprintf("%q: %q", attribute, uid); !strings.Contains(response, want) {
+			t.Errorf("LDS response missing Mixer attribute %s", want)
+		}
+	}
+}
+
+func TestListenerDiscoveryIngress(t *testing.T) {
+	registry := memory.Make(model.IstioConfigTypes)
+	ds := makeDiscoveryService(t, registry)
+	url := fmt.Sprintf("/v1/listeners/%s/%s", ds.MeshConfig.IstioServiceCluster, ingressNode)
+	recorder := httptest.NewRecorder()
+	container := restful.NewContainer()
+	ds.Register(container)
+	container.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("ListListeners() for ingress => got status %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestConfigDump(t *testing.T) {
+	registry := memory.Make(model.IstioConfigTypes)
+	addFaultRoute(registry, t)
+	ds := makeDiscoveryService(t, registry)
+	url := "/v1/config_dump/" + mock.HostInstanceV0
+	response := makeDiscoveryRequest(ds, "GET", url, t)
+	compareResponse(response, "testdata/config-dump.json", t)
+}
+
+func TestConfigDumpIngress(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	dump, err := ds.configDump(ingressNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.Config != nil || len(dump.Listeners) != 0 || len(dump.HostInstances) != 0 {
+		t.Errorf("unexpected sidecar configuration for ingress: %#v", dump)
+	}
+}
+
 func TestSecretDiscovery(t *testing.T) {
 	registry := memory.Make(model.IstioConfigTypes)
 	addIngressRoutes(registry, t)
@@ -386,3 +491,189 @@ func TestDiscoveryCache(t *testing.T) {
 		compareResponse(got, c.wantCache, t)
 	}
 }
+
+func TestDiscoveryCacheEviction(t *testing.T) {
+	registry := memory.Make(model.IstioConfigTypes)
+	addFaultRoute(registry, t)
+	rules, err := registry.List(model.RouteRule, model.NamespaceAll)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("List(%q) => got %v, %v", model.RouteRule, rules, err)
+	}
+	ds := makeDiscoveryService(t, registry)
+
+	sdsHello := "/v1/registration/" + mock.HelloService.Key(mock.HelloService.Ports[0], nil)
+	sdsWorld := "/v1/registration/" + mock.WorldService.Key(mock.WorldService.Ports[0], nil)
+	rdsV0 := fmt.Sprintf("/v1/routes/80/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV0)
+	rdsV1 := fmt.Sprintf("/v1/routes/80/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV1)
+	caches := map[string]*discoveryCache{
+		sdsHello: ds.sdsCache,
+		sdsWorld: ds.sdsCache,
+		rdsV0:    ds.rdsCache,
+		rdsV1:    ds.rdsCache,
+	}
+
+	cases := []struct {
+		name       string
+		invalidate func()
+		evicted    map[string]bool
+	}{
+		{
+			// fault rule is source based and applies only to v0
+			name:       "route rule",
+			invalidate: func() { ds.invalidateConfig(rules[0]) },
+			evicted:    map[string]bool{rdsV0: true},
+		},
+		{
+			name:       "world instances",
+			invalidate: func() { ds.invalidateInstance(&model.ServiceInstance{Service: mock.WorldService}) },
+			evicted:    map[string]bool{sdsWorld: true},
+		},
+		{
+			name:       "hello instances",
+			invalidate: func() { ds.invalidateInstance(&model.ServiceInstance{Service: mock.HelloService}) },
+			evicted:    map[string]bool{sdsHello: true, rdsV0: true, rdsV1: true},
+		},
+		{
+			name:       "world service",
+			invalidate: func() { ds.invalidateService(mock.WorldService) },
+			evicted:    map[string]bool{sdsWorld: true, rdsV0: true, rdsV1: true},
+		},
+	}
+	for _, c := range cases {
+		for path := range caches {
+			_ = makeDiscoveryRequest(ds, "GET", path, t)
+		}
+		c.invalidate()
+		for path, cache := range caches {
+			key, err := url.Parse(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, cached := cache.cachedDiscoveryResponse(key.String()); cached == c.evicted[path] {
+				t.Errorf("%s: got cached %t for %s", c.name, cached, path)
+			}
+		}
+	}
+}
+
+func TestDiscoveryConfigEvents(t *testing.T) {
+	store := memory.NewController(memory.Make(model.IstioConfigTypes))
+	mesh := proxy.DefaultMeshConfig()
+	ds, err := NewDiscoveryService(
+		&mockController{},
+		store,
+		&proxy.Context{
+			Discovery:  mock.Discovery,
+			Accounts:   mock.Discovery,
+			Config:     model.MakeIstioStore(store),
+			MeshConfig: &mesh,
+		},
+		DiscoveryServiceOptions{EnableCaching: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go store.Run(stop)
+
+	url := fmt.Sprintf("/v1/routes/80/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV0)
+	_ = makeDiscoveryRequest(ds, "GET", url, t)
+	if _, cached := ds.rdsCache.cachedDiscoveryResponse(url); !cached {
+		t.Fatalf("expected cached response for %s", url)
+	}
+
+	// fault rule applies to v0 and evicts its routes
+	addFaultRoute(store, t)
+	for i := 0; ; i++ {
+		if _, cached := ds.rdsCache.cachedDiscoveryResponse(url); !cached {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("expected eviction of %s after adding a route rule", url)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	response := makeDiscoveryRequest(ds, "GET", url, t)
+	compareResponse(response, "testdata/rds-fault.json", t)
+}
+
+func TestDiscoveryConditionalRequest(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	container := restful.NewContainer()
+	ds.Register(container)
+	url := fmt.Sprintf("/v1/clusters/%s/%s", ds.MeshConfig.IstioServiceCluster, mock.HostInstanceV0)
+	get := func(header map[string]string) *http.Response {
+		httpRequest, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			httpRequest.Header.Set(k, v)
+		}
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		return httpWriter.Result()
+	}
+
+	plain := get(nil)
+	etag := plain.Header.Get("ETag")
+	body, err := ioutil.ReadAll(plain.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("got status %d and ETag %q", plain.StatusCode, etag)
+	}
+
+	if resp := get(map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("got status %d for matching ETag, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+	if resp := get(map[string]string{"If-None-Match": `"stale", ` + etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("got status %d for matching ETag in a list, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+	if resp := get(map[string]string{"If-None-Match": `"stale"`}); resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d for stale ETag, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// disabling the cache must not change the entity tag
+	ds.cdsCache.disabled = true
+	if resp := get(map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("got status %d for matching ETag without cache, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+
+	compressed := get(map[string]string{"Accept-Encoding": "deflate, gzip"})
+	if compressed.Header.Get("Content-Encoding") != "gzip" || compressed.Header.Get("ETag") != etag {
+		t.Fatalf("got headers %v for gzip request", compressed.Header)
+	}
+	reader, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncompressed, err := ioutil.ReadAll(reader); err != nil || !bytes.Equal(uncompressed, body) {
+		t.Errorf("got gzip body %q (%v), want %q", uncompressed, err, body)
+	}
+
+	if resp := get(map[string]string{"Accept-Encoding": "gzip;q=0"}); resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("got headers %v for refused gzip", resp.Header)
+	}
+}
+
+func TestDiscoveryMetrics(t *testing.T) {
+	ds := makeDiscoveryService(t, memory.Make(model.IstioConfigTypes))
+	url := "/v1/registration/" + mock.HelloService.Key(mock.HelloService.Ports[0], nil)
+	_ = makeDiscoveryRequest(ds, "GET", url, t)
+	_ = makeDiscoveryRequest(ds, "GET", url, t)
+
+	metrics := string(makeDiscoveryRequest(ds, "GET", "/metrics", t))
+	for _, want := range []string{
+		`pilot_discovery_requests_total{type="sds"}`,
+		`pilot_discovery_request_duration_seconds_count{type="sds"}`,
+		`pilot_discovery_cache_hits_total{type="sds"}`,
+		`pilot_discovery_cache_misses_total{type="sds"}`,
+		`pilot_discovery_generation_duration_seconds_count{node_type="none",type="sds"}`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
@@ -77,7 +77,7 @@ func generateEgress(mesh *proxyconfig.ProxyMeshConfig) *Config {
 	port := getEgressProxyPort(mesh)
 	listener := buildHTTPListener(mesh, nil, WildcardAddress, port, true, false)
 	listener = applyInboundAuth(listener, mesh)
-	config := buildConfig([]*Listener{listener}, nil, mesh)
+	config := buildConfig([]*Listener{listener}, nil, false, mesh)
 	if mesh.AuthPolicy == proxyconfig.ProxyMeshConfig_MUTUAL_TLS {
 		config.Hash = generateCertHash(mesh.AuthCertsPath)
 	}
@@ -32,7 +32,7 @@ func addIngressRoutes(r model.ConfigStore, t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if _, err = r.Post(msg); err != nil {
+		if _, err = r.Post(msg, ""); err != nil {
 			t.Fatal(err)
 		}
 	}
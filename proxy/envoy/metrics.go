@@ -0,0 +1,109 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Discovery service metrics are labeled by the discovery type (sds, cds, rds,
+// lds, or eds for ADS). Proxy nodes are not used as labels to bound the number
+// of time series; generation time is labeled by the node type instead.
+var (
+	requestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "discovery",
+		Name:      "requests_total",
+		Help:      "Number of discovery requests.",
+	}, []string{"type"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pilot",
+		Subsystem: "discovery",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of discovery requests.",
+	}, []string{"type"})
+
+	cacheHitCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "discovery",
+		Name:      "cache_hits_total",
+		Help:      "Number of discovery responses served from the cache.",
+	}, []string{"type"})
+
+	cacheMissCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "discovery",
+		Name:      "cache_misses_total",
+		Help:      "Number of discovery responses missing from the cache.",
+	}, []string{"type"})
+
+	generationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pilot",
+		Subsystem: "discovery",
+		Name:      "generation_duration_seconds",
+		Help:      "Time to generate the discovery response for a proxy node.",
+	}, []string{"type", "node_type"})
+
+	adsRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pilot",
+		Subsystem: "ads",
+		Name:      "requests_total",
+		Help:      "Number of discovery requests received on ADS streams.",
+	}, []string{"type"})
+
+	adsGenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pilot",
+		Subsystem: "ads",
+		Name:      "generation_duration_seconds",
+		Help:      "Time to generate the ADS resources of a type for a proxy node.",
+	}, []string{"type", "node_type"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCounter, requestDuration, cacheHitCounter, cacheMissCounter, generationDuration,
+		adsRequestCounter, adsGenerationDuration)
+}
+
+// instrument records the number and the latency of requests for a discovery type
+func instrument(typ string, f restful.RouteFunction) restful.RouteFunction {
+	return func(request *restful.Request, response *restful.Response) {
+		start := time.Now()
+		f(request, response)
+		requestCounter.WithLabelValues(typ).Inc()
+		requestDuration.WithLabelValues(typ).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeGeneration records the time elapsed since the start of response generation
+func observeGeneration(typ, node string, start time.Time) {
+	generationDuration.WithLabelValues(typ, nodeType(node)).Observe(time.Since(start).Seconds())
+}
+
+// nodeType classifies a proxy node as ingress, egress, or sidecar. SDS
+// responses are shared by all nodes and have no node.
+func nodeType(node string) string {
+	switch node {
+	case ingressNode, egressNode:
+		return node
+	case "":
+		return "none"
+	default:
+		return "sidecar"
+	}
+}
@@ -42,6 +42,13 @@ const (
 	// CDSName is the name of CDS cluster
 	CDSName = "cds"
 
+	// LDSName is the name of LDS cluster
+	LDSName = "lds"
+
+	// VirtualListenerName is the name of the listener that receives the
+	// iptables redirect and hands connections off to other listeners
+	VirtualListenerName = "virtual"
+
 	// ClusterTypeStrictDNS name for clusters of type 'strict_dns'
 	ClusterTypeStrictDNS = "strict_dns"
 
@@ -100,6 +107,7 @@ func protoDurationToMS(dur *duration.Duration) int64 {
 type Config struct {
 	RootRuntime        *RootRuntime   `json:"runtime,omitempty"`
 	Listeners          Listeners      `json:"listeners"`
+	LDS                *LDSCluster    `json:"lds,omitempty"`
 	Admin              Admin          `json:"admin"`
 	ClusterManager     ClusterManager `json:"cluster_manager"`
 	StatsdUDPIPAddress string         `json:"statsd_udp_ip_address,omitempty"`
@@ -462,6 +470,7 @@ type NetworkFilter struct {
 
 // Listener definition
 type Listener struct {
+	Name           string           `json:"name"`
 	Address        string           `json:"address"`
 	Filters        []*NetworkFilter `json:"filters"`
 	SSLContext     *SSLContext      `json:"ssl_context,omitempty"`
@@ -650,6 +659,12 @@ type DiscoveryCluster struct {
 	RefreshDelayMs int64    `json:"refresh_delay_ms"`
 }
 
+// LDSCluster is a reference to LDS cluster by name
+type LDSCluster struct {
+	Cluster        string `json:"cluster"`
+	RefreshDelayMs int64  `json:"refresh_delay_ms"`
+}
+
 // RDS definition
 type RDS struct {
 	Cluster         string `json:"cluster"`
@@ -315,3 +315,46 @@ func buildTCPRoute(cluster *Cluster, addresses []string) *TCPRoute {
 	}
 	return route
 }
+
+// buildTCPRouteFromRule translates a route rule with at most one destination
+// to an Envoy TCP route for the service address.
+func buildTCPRouteFromRule(rule *proxyconfig.RouteRule, service *model.Service, port *model.Port) *TCPRoute {
+	destination := rule.Destination
+	var tags model.Tags
+	if len(rule.Route) > 0 {
+		dst := rule.Route[0]
+		if dst.Destination != "" {
+			destination = dst.Destination
+		}
+		tags = dst.Tags
+	}
+
+	cluster := buildOutboundCluster(destination, port, tags)
+	route := buildTCPRoute(cluster, []string{service.Address})
+
+	if match := rule.GetMatch().GetTcp(); match != nil {
+		if len(match.DestinationSubnet) > 0 {
+			route.DestinationIPList = buildSubnets(match.DestinationSubnet)
+		}
+		route.SourceIPList = buildSubnets(match.SourceSubnet)
+	}
+
+	return route
+}
+
+// hasSubnets checks whether the L4 match attributes restrict the subnets
+func hasSubnets(match *proxyconfig.L4MatchAttributes) bool {
+	return match != nil && (len(match.SourceSubnet) > 0 || len(match.DestinationSubnet) > 0)
+}
+
+// buildSubnets converts subnets in dot-decimal or CIDR notation to CIDR notation
+func buildSubnets(subnets []string) []string {
+	var out []string
+	for _, subnet := range subnets {
+		if !strings.Contains(subnet, "/") {
+			subnet += "/32"
+		}
+		out = append(out, subnet)
+	}
+	return out
+}
@@ -0,0 +1,344 @@
+// Copyright 2017 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Functions below translate the Envoy v1 configuration structures produced by
+// the generation code into the Envoy v2 API resources served by ADS. Network
+// filters are passed through in the v1 format using the deprecated_v1
+// adapter since their v2 counterparts are not yet available.
+
+package envoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	envoyapi "github.com/envoyproxy/data-plane-api/api"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+func msToDuration(ms int64) *duration.Duration {
+	return ptypes.DurationProto(time.Duration(ms) * time.Millisecond)
+}
+
+func uint32Value(value int) *wrappers.UInt32Value {
+	if value <= 0 {
+		return nil
+	}
+	return &wrappers.UInt32Value{Value: uint32(value)}
+}
+
+func fileDataSource(path string) *envoyapi.DataSource {
+	if path == "" {
+		return nil
+	}
+	return &envoyapi.DataSource{Specifier: &envoyapi.DataSource_Filename{Filename: path}}
+}
+
+// buildV2Address parses an address in the v1 URL format tcp://ip:port
+func buildV2Address(url string) (*envoyapi.Address, error) {
+	if !strings.HasPrefix(url, "tcp://") {
+		return nil, fmt.Errorf("unsupported address %q", url)
+	}
+	host, portStr, err := net.SplitHostPort(strings.TrimPrefix(url, "tcp://"))
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	return buildV2SocketAddress(host, port), nil
+}
+
+func buildV2SocketAddress(host string, port int) *envoyapi.Address {
+	return &envoyapi.Address{
+		Address: &envoyapi.Address_SocketAddress{
+			SocketAddress: &envoyapi.SocketAddress{
+				Address:       host,
+				PortSpecifier: &envoyapi.SocketAddress_PortValue{PortValue: uint32(port)},
+			},
+		},
+	}
+}
+
+// buildV2Cluster translates a cluster. SDS clusters are served by EDS over
+// the aggregated stream using the service key as the resource name.
+func buildV2Cluster(cluster *Cluster) (*envoyapi.Cluster, error) {
+	out := &envoyapi.Cluster{
+		Name:                     cluster.Name,
+		ConnectTimeout:           msToDuration(cluster.ConnectTimeoutMs),
+		MaxRequestsPerConnection: uint32Value(cluster.MaxRequestsPerConnection),
+	}
+
+	switch cluster.Type {
+	case SDSName:
+		out.Type = envoyapi.Cluster_EDS
+		out.EdsClusterConfig = &envoyapi.Cluster_EdsClusterConfig{
+			EdsConfig: &envoyapi.ConfigSource{
+				ConfigSourceSpecifier: &envoyapi.ConfigSource_Ads{Ads: &envoyapi.AggregatedConfigSource{}},
+			},
+			ServiceName: cluster.ServiceName,
+		}
+	case ClusterTypeStatic:
+		out.Type = envoyapi.Cluster_STATIC
+	case ClusterTypeStrictDNS:
+		out.Type = envoyapi.Cluster_STRICT_DNS
+	default:
+		return nil, fmt.Errorf("unsupported type %q for cluster %q", cluster.Type, cluster.Name)
+	}
+
+	switch cluster.LbType {
+	case LbTypeRoundRobin:
+		out.LbPolicy = envoyapi.Cluster_ROUND_ROBIN
+	case "least_request":
+		out.LbPolicy = envoyapi.Cluster_LEAST_REQUEST
+	case "random":
+		out.LbPolicy = envoyapi.Cluster_RANDOM
+	default:
+		return nil, fmt.Errorf("unsupported load balancer %q for cluster %q", cluster.LbType, cluster.Name)
+	}
+
+	for _, host := range cluster.Hosts {
+		address, err := buildV2Address(host.URL)
+		if err != nil {
+			return nil, err
+		}
+		out.Hosts = append(out.Hosts, address)
+	}
+
+	if cluster.Features == ClusterFeatureHTTP2 {
+		out.Http2ProtocolOptions = &envoyapi.Http2ProtocolOptions{}
+	}
+
+	switch ssl := cluster.SSLContext.(type) {
+	case nil:
+	case *SSLContextWithSAN:
+		out.TlsContext = &envoyapi.UpstreamTlsContext{
+			CommonTlsContext: &envoyapi.CommonTlsContext{
+				TlsCertificates: []*envoyapi.TlsCertificate{{
+					CertificateChain: fileDataSource(ssl.CertChainFile),
+					PrivateKey:       fileDataSource(ssl.PrivateKeyFile),
+				}},
+				ValidationContext: &envoyapi.CertificateValidationContext{
+					TrustedCa:            fileDataSource(ssl.CaCertFile),
+					VerifySubjectAltName: ssl.VerifySubjectAltName,
+				},
+			},
+		}
+	case *SSLContextExternal:
+		out.TlsContext = &envoyapi.UpstreamTlsContext{
+			CommonTlsContext: &envoyapi.CommonTlsContext{
+				ValidationContext: &envoyapi.CertificateValidationContext{
+					TrustedCa: fileDataSource(ssl.CaCertFile),
+				},
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SSL context %#v for cluster %q", ssl, cluster.Name)
+	}
+
+	if cb := cluster.CircuitBreaker; cb != nil {
+		out.CircuitBreakers = &envoyapi.CircuitBreakers{
+			Thresholds: []*envoyapi.CircuitBreakers_Thresholds{{
+				MaxConnections:     uint32Value(cb.Default.MaxConnections),
+				MaxPendingRequests: uint32Value(cb.Default.MaxPendingRequests),
+				MaxRequests:        uint32Value(cb.Default.MaxRequests),
+				MaxRetries:         uint32Value(cb.Default.MaxRetries),
+			}},
+		}
+	}
+
+	if outlier := cluster.OutlierDetection; outlier != nil {
+		out.OutlierDetection = &envoyapi.OutlierDetection{
+			Consecutive_5Xx:    uint32Value(outlier.ConsecutiveErrors),
+			MaxEjectionPercent: uint32Value(outlier.MaxEjectionPercent),
+		}
+		if outlier.IntervalMS > 0 {
+			out.OutlierDetection.Interval = msToDuration(outlier.IntervalMS)
+		}
+		if outlier.BaseEjectionTimeMS > 0 {
+			out.OutlierDetection.BaseEjectionTime = msToDuration(outlier.BaseEjectionTimeMS)
+		}
+	}
+
+	return out, nil
+}
+
+// buildV2ClusterLoadAssignment translates SDS hosts for a service key
+func buildV2ClusterLoadAssignment(serviceKey string, hosts []*host) *envoyapi.ClusterLoadAssignment {
+	endpoints := make([]*envoyapi.LbEndpoint, 0, len(hosts))
+	for _, h := range hosts {
+		endpoints = append(endpoints, &envoyapi.LbEndpoint{
+			Endpoint:            &envoyapi.Endpoint{Address: buildV2SocketAddress(h.Address, h.Port)},
+			LoadBalancingWeight: uint32Value(h.Weight),
+		})
+	}
+	return &envoyapi.ClusterLoadAssignment{
+		ClusterName: serviceKey,
+		Endpoints:   []*envoyapi.LocalityLbEndpoints{{LbEndpoints: endpoints}},
+	}
+}
+
+func buildV2Route(route *HTTPRoute) *envoyapi.Route {
+	match := &envoyapi.RouteMatch{}
+	if route.Path != "" {
+		match.PathSpecifier = &envoyapi.RouteMatch_Path{Path: route.Path}
+	} else {
+		match.PathSpecifier = &envoyapi.RouteMatch_Prefix{Prefix: route.Prefix}
+	}
+	for _, header := range route.Headers {
+		matcher := &envoyapi.HeaderMatcher{Name: header.Name, Value: header.Value}
+		if header.Regex {
+			matcher.Regex = &wrappers.BoolValue{Value: true}
+		}
+		match.Headers = append(match.Headers, matcher)
+	}
+
+	if route.PathRedirect != "" || route.HostRedirect != "" {
+		return &envoyapi.Route{
+			Match: match,
+			Action: &envoyapi.Route_Redirect{Redirect: &envoyapi.RedirectAction{
+				HostRedirect: route.HostRedirect,
+				PathRedirect: route.PathRedirect,
+			}},
+		}
+	}
+
+	action := &envoyapi.RouteAction{PrefixRewrite: route.PrefixRewrite}
+	if route.WeightedClusters != nil {
+		weighted := &envoyapi.WeightedCluster{}
+		for _, entry := range route.WeightedClusters.Clusters {
+			weighted.Clusters = append(weighted.Clusters, &envoyapi.WeightedCluster_ClusterWeight{
+				Name:   entry.Name,
+				Weight: &wrappers.UInt32Value{Value: uint32(entry.Weight)},
+			})
+		}
+		action.ClusterSpecifier = &envoyapi.RouteAction_WeightedClusters{WeightedClusters: weighted}
+	} else {
+		action.ClusterSpecifier = &envoyapi.RouteAction_Cluster{Cluster: route.Cluster}
+	}
+	if route.HostRewrite != "" {
+		action.HostRewriteSpecifier = &envoyapi.RouteAction_HostRewrite{HostRewrite: route.HostRewrite}
+	}
+	if route.TimeoutMS > 0 {
+		action.Timeout = msToDuration(route.TimeoutMS)
+	}
+	if retry := route.RetryPolicy; retry != nil {
+		action.RetryPolicy = &envoyapi.RouteAction_RetryPolicy{
+			RetryOn:    retry.Policy,
+			NumRetries: uint32Value(retry.NumRetries),
+		}
+		if retry.PerTryTimeoutMS > 0 {
+			action.RetryPolicy.PerTryTimeout = msToDuration(retry.PerTryTimeoutMS)
+		}
+	}
+
+	return &envoyapi.Route{Match: match, Action: &envoyapi.Route_Route{Route: action}}
+}
+
+// buildV2RouteConfiguration translates the route config for a port
+func buildV2RouteConfiguration(name string, config *HTTPRouteConfig) *envoyapi.RouteConfiguration {
+	out := &envoyapi.RouteConfiguration{Name: name}
+	for _, host := range config.VirtualHosts {
+		routes := make([]*envoyapi.Route, 0, len(host.Routes))
+		for _, route := range host.Routes {
+			routes = append(routes, buildV2Route(route))
+		}
+		out.VirtualHosts = append(out.VirtualHosts, &envoyapi.VirtualHost{
+			Name:    host.Name,
+			Domains: host.Domains,
+			Routes:  routes,
+		})
+	}
+	return out
+}
+
+// buildV2Struct converts a filter configuration to a protobuf struct via its
+// JSON representation
+func buildV2Struct(config interface{}) (*structpb.Struct, error) {
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	out := &structpb.Struct{}
+	if err = jsonpb.UnmarshalString(string(bytes), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// buildV2Listener translates a listener and its network filters
+func buildV2Listener(listener *Listener) (*envoyapi.Listener, error) {
+	address, err := buildV2Address(listener.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+	chain := &envoyapi.FilterChain{}
+	for _, filter := range listener.Filters {
+		config, err := buildV2Struct(filter.Config)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("filter %q in listener %q: %v", filter.Name, listener.Name, err))
+			continue
+		}
+		chain.Filters = append(chain.Filters, &envoyapi.Filter{
+			Name:         filter.Name,
+			Config:       config,
+			DeprecatedV1: &envoyapi.Filter_DeprecatedV1{Type: filter.Type},
+		})
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	if ssl := listener.SSLContext; ssl != nil {
+		chain.TlsContext = &envoyapi.DownstreamTlsContext{
+			CommonTlsContext: &envoyapi.CommonTlsContext{
+				TlsCertificates: []*envoyapi.TlsCertificate{{
+					CertificateChain: fileDataSource(ssl.CertChainFile),
+					PrivateKey:       fileDataSource(ssl.PrivateKeyFile),
+				}},
+			},
+		}
+		if ssl.CaCertFile != "" {
+			chain.TlsContext.CommonTlsContext.ValidationContext = &envoyapi.CertificateValidationContext{
+				TrustedCa: fileDataSource(ssl.CaCertFile),
+			}
+			chain.TlsContext.RequireClientCertificate = &wrappers.BoolValue{Value: true}
+		}
+	}
+
+	out := &envoyapi.Listener{
+		Name:         listener.Name,
+		Address:      address,
+		FilterChains: []*envoyapi.FilterChain{chain},
+		DeprecatedV1: &envoyapi.Listener_DeprecatedV1{
+			BindToPort: &wrappers.BoolValue{Value: listener.BindToPort},
+		},
+	}
+	if listener.UseOriginalDst {
+		out.UseOriginalDst = &wrappers.BoolValue{Value: true}
+	}
+	return out, nil
+}
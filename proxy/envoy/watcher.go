@@ -27,7 +27,9 @@ import (
 	"istio.io/pilot/proxy"
 )
 
-// Watcher observes service registry and triggers a reload on a change
+// Watcher observes service registry and triggers a reload on a change of the
+// bootstrap configuration. Listeners, clusters, and routes are delivered to the
+// proxy through the discovery service and do not require a restart.
 type Watcher interface {
 	Run(stop <-chan struct{})
 }
@@ -39,7 +41,7 @@ type watcher struct {
 }
 
 // NewWatcher creates a new watcher instance with an agent
-func NewWatcher(ctl model.Controller, configCache model.ConfigStoreCache, proxyCtx *proxy.Context) (Watcher, error) {
+func NewWatcher(ctl model.Controller, proxyCtx *proxy.Context) (Watcher, error) {
 	glog.V(2).Infof("Local instance address: %s", proxyCtx.IPAddress)
 
 	if proxyCtx.MeshConfig.StatsdUdpAddress != "" {
@@ -61,22 +63,12 @@ func NewWatcher(ctl model.Controller, configCache model.ConfigStoreCache, proxyC
 		ctl:     ctl,
 	}
 
-	if err := ctl.AppendServiceHandler(func(*model.Service, model.Event) { out.reload() }); err != nil {
-		return nil, err
-	}
-
+	// passthrough listeners in the bootstrap configuration depend on the co-located instances
 	// TODO: notification granularity: restrict the notification callback to co-located instances (e.g. with the same IP)
-	// TODO: editing pod tags directly does not trigger instance handlers, we need to listen on pod resources.
 	if err := ctl.AppendInstanceHandler(func(*model.ServiceInstance, model.Event) { out.reload() }); err != nil {
 		return nil, err
 	}
 
-	if configCache != nil {
-		handler := func(model.Config, model.Event) { out.reload() }
-		configCache.RegisterEventHandler(model.RouteRule, handler)
-		configCache.RegisterEventHandler(model.DestinationPolicy, handler)
-	}
-
 	return out, nil
 }
 
@@ -33,12 +33,12 @@ func TestHandlers(t *testing.T) {
 		Config:     model.MakeIstioStore(memory.Make(model.IstioConfigTypes)),
 		MeshConfig: &mesh,
 	}
-	_, err := NewWatcher(&controller, nil, &context)
+	_, err := NewWatcher(&controller, &context)
 	if err != nil {
 		t.Errorf("failed creating watcher %v", err)
 	}
-	if controller.handlers != 2 {
-		t.Errorf("expected handlers for services, instances, got %d", controller.handlers)
+	if controller.handlers != 1 {
+		t.Errorf("expected handlers for instances, got %d", controller.handlers)
 	}
 }
 
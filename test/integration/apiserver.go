@@ -149,6 +149,10 @@ func (r *apiServerTest) setup() error {
 	testURL = "http://localhost:8081/v1alpha1/config/route-rule/" + r.Namespace + "/reviews-default"
 	testNamespaceURL = "http://localhost:8081/v1alpha1/config/route-rule/" + r.Namespace
 
+	// the rules are created in the test namespace
+	jsonRule["namespace"] = r.Namespace
+	jsonRule2["namespace"] = r.Namespace
+
 	return nil
 }
 
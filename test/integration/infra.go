@@ -275,11 +275,11 @@ func (infra *infra) applyConfig(inFile string, data map[string]string, typ strin
 		return err
 	}
 
-	_, exists, rev := istioClient.Get(typ, schema.Key(v))
+	_, exists, rev := istioClient.Get(typ, schema.Key(v), infra.Namespace)
 	if exists {
-		_, err = istioClient.Put(v, rev)
+		_, err = istioClient.Put(v, infra.Namespace, rev)
 	} else {
-		_, err = istioClient.Post(v)
+		_, err = istioClient.Post(v, infra.Namespace)
 	}
 	if err != nil {
 		return err
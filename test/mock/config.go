@@ -60,8 +60,9 @@ func Make(i int) *MockConfig {
 	}
 }
 
-// CheckMapInvariant validates operational invariants of an empty config registry
-func CheckMapInvariant(r model.ConfigStore, t *testing.T, n int) {
+// CheckMapInvariant validates operational invariants of an empty config
+// registry using the objects in a namespace
+func CheckMapInvariant(r model.ConfigStore, t *testing.T, namespace string, n int) {
 	// check that the config descriptor is the mock config descriptor
 	_, contains := r.ConfigDescriptor().GetByType(Type)
 	if !contains {
@@ -76,7 +77,7 @@ func CheckMapInvariant(r model.ConfigStore, t *testing.T, n int) {
 
 	// post all elements
 	for _, elt := range elts {
-		if _, err := r.Post(elt); err != nil {
+		if _, err := r.Post(elt, namespace); err != nil {
 			t.Error(err)
 		}
 	}
@@ -85,103 +86,119 @@ func CheckMapInvariant(r model.ConfigStore, t *testing.T, n int) {
 
 	// check that elements are stored
 	for i, elt := range elts {
-		if v1, ok, rev := r.Get(Type, elts[i].Key); !ok || !reflect.DeepEqual(v1, elt) {
+		if v1, ok, rev := r.Get(Type, elts[i].Key, namespace); !ok || !reflect.DeepEqual(v1, elt) {
 			t.Errorf("wanted %v, got %v", elt, v1)
 		} else {
 			revs[i] = rev
 		}
 	}
 
-	if _, err := r.Post(elts[0]); err == nil {
+	if _, err := r.Post(elts[0], namespace); err == nil {
 		t.Error("expected error posting twice")
 	}
 
-	if _, err := r.Post(nil); err == nil {
+	if _, err := r.Post(nil, namespace); err == nil {
 		t.Error("expected error posting invalid object")
 	}
 
-	if _, err := r.Post(&MockConfig{}); err == nil {
+	if _, err := r.Post(&MockConfig{}, namespace); err == nil {
 		t.Error("expected error posting invalid object")
 	}
 
-	if _, err := r.Put(nil, revs[0]); err == nil {
+	if _, err := r.Put(nil, namespace, revs[0]); err == nil {
 		t.Error("expected error putting invalid object")
 	}
 
-	if _, err := r.Put(&MockConfig{}, revs[0]); err == nil {
+	if _, err := r.Put(&MockConfig{}, namespace, revs[0]); err == nil {
 		t.Error("expected error putting invalid object")
 	}
 
-	if _, err := r.Put(&MockConfig{Key: "missing"}, revs[0]); err == nil {
+	if _, err := r.Put(&MockConfig{Key: "missing"}, namespace, revs[0]); err == nil {
 		t.Error("expected error putting missing object with a missing key")
 	}
 
-	if _, err := r.Put(elts[0], ""); err == nil {
+	if _, err := r.Put(elts[0], namespace, ""); err == nil {
 		t.Error("expected error putting object without revision")
 	}
 
-	if _, err := r.Put(elts[0], "missing"); err == nil {
+	if _, err := r.Put(elts[0], namespace, "missing"); err == nil {
 		t.Error("expected error putting object with a bad revision")
 	}
 
 	// check for missing type
-	if l, _ := r.List("missing"); len(l) > 0 {
+	if l, _ := r.List("missing", namespace); len(l) > 0 {
 		t.Errorf("unexpected objects for missing type")
 	}
 
 	// check for missing element
-	if _, ok, _ := r.Get(Type, "missing"); ok {
+	if _, ok, _ := r.Get(Type, "missing", namespace); ok {
 		t.Error("unexpected configuration object found")
 	}
 
 	// check for missing element
-	if _, ok, _ := r.Get("missing", "missing"); ok {
+	if _, ok, _ := r.Get("missing", "missing", namespace); ok {
 		t.Error("unexpected configuration object found")
 	}
 
 	// delete missing elements
-	if err := r.Delete("missing", "missing"); err == nil {
+	if err := r.Delete("missing", "missing", namespace); err == nil {
 		t.Error("expected error on deletion of missing type")
 	}
 
 	// delete missing elements
-	if err := r.Delete(Type, "missing"); err == nil {
+	if err := r.Delete(Type, "missing", namespace); err == nil {
 		t.Error("expected error on deletion of missing element")
 	}
 
 	// list elements
-	l, err := r.List(Type)
+	l, err := r.List(Type, namespace)
 	if err != nil {
 		t.Errorf("List error %#v, %v", l, err)
 	}
 	if len(l) != n {
 		t.Errorf("wanted %d element(s), got %d in %v", n, len(l), l)
 	}
+	for _, config := range l {
+		if config.Namespace != namespace {
+			t.Errorf("wanted namespace %q, got %q", namespace, config.Namespace)
+		}
+	}
+
+	// elements are not visible in other namespaces
+	if _, ok, _ := r.Get(Type, elts[0].Key, namespace+"-other"); ok {
+		t.Error("unexpected configuration object found in another namespace")
+	}
+	if l, _ := r.List(Type, namespace+"-other"); len(l) > 0 {
+		t.Errorf("unexpected objects in another namespace: %v", l)
+	}
+	if err := r.Delete(Type, elts[0].Key, namespace+"-other"); err == nil {
+		t.Error("expected error on deletion of an element in another namespace")
+	}
 
 	// update all elements
 	for i := 0; i < n; i++ {
 		elts[i] = Make(i)
 		elts[i].Pairs[0].Value += "(updated)"
-		if _, err = r.Put(elts[i], revs[i]); err != nil {
+		if _, err = r.Put(elts[i], namespace, revs[i]); err != nil {
 			t.Error(err)
 		}
 	}
 
 	// check that elements are stored
 	for i, elt := range elts {
-		if v1, ok, _ := r.Get(Type, elts[i].Key); !ok || !reflect.DeepEqual(v1, elt) {
+		if v1, ok, _ := r.Get(Type, elts[i].Key, namespace); !ok || !reflect.DeepEqual(v1, elt) {
 			t.Errorf("wanted %v, got %v", elt, v1)
 		}
 	}
 
 	// delete all elements
 	for i := range elts {
-		if err = r.Delete(Type, elts[i].Key); err != nil {
+		if err = r.Delete(Type, elts[i].Key, namespace); err != nil {
 			t.Error(err)
 		}
 	}
 
-	l, err = r.List(Type)
+	l, err = r.List(Type, namespace)
 	if err != nil {
 		t.Error(err)
 	}
@@ -134,6 +134,15 @@ type ServiceDiscovery struct {
 	versions int
 }
 
+// NewDiscovery builds a mock discovery interface over a set of services with
+// the given number of versions per service
+func NewDiscovery(services map[string]*model.Service, versions int) *ServiceDiscovery {
+	return &ServiceDiscovery{
+		services: services,
+		versions: versions,
+	}
+}
+
 // Services implements discovery interface
 func (sd *ServiceDiscovery) Services() []*model.Service {
 	out := make([]*model.Service, 0, len(sd.services))
@@ -162,7 +171,7 @@ func (sd *ServiceDiscovery) Instances(hostname string, ports []string, tags mode
 	for _, name := range ports {
 		if port, ok := service.Ports.Get(name); ok {
 			for v := 0; v < sd.versions; v++ {
-				if tags.HasSubsetOf(map[string]string{"version": fmt.Sprintf("v%d", v)}) {
+				if tags.Matches(map[string]string{"version": fmt.Sprintf("v%d", v)}) {
 					out = append(out, MakeInstance(service, port, v))
 				}
 			}